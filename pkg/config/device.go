@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
@@ -36,6 +38,21 @@ type ModbusDeviceData struct {
 	// Width is the number of registers to read, starting from the `Address`.
 	Width uint16
 
+	// Addresses, when set, is an ordered list of register addresses whose
+	// registers are concatenated before decoding, for values split across
+	// non-contiguous registers. Address is ignored and Width, if set, must be
+	// the number of addresses.
+	Addresses []uint16 `yaml:"addresses,omitempty"`
+
+	// Count, when more than 1, makes the device an array of Count values of
+	// Width registers each, starting at Address. The device has one reading
+	// per value, with the index of the value in the reading context.
+	Count uint16 `yaml:"count,omitempty"`
+
+	// Stride is the number of registers from the start of one array value to
+	// the start of the next. Defaults to Width.
+	Stride uint16 `yaml:"stride,omitempty"`
+
 	// Type is the type of the data held in the registers. The supported
 	// types are as follows:
 	//
@@ -48,19 +65,322 @@ type ModbusDeviceData struct {
 	//  "s64", "int64":   signed 64-bit integer
 	//  "f32", "float32": 32-bit floating point number
 	//  "f64", "float64": 64-bit floating point number
+	//  "bcd16":          4 digit packed binary-coded decimal
+	//  "bcd32":          8 digit packed binary-coded decimal
+	//  "sbcd16":         3 digit packed binary-coded decimal with sign nibble
+	//  "sbcd32":         7 digit packed binary-coded decimal with sign nibble
+	//  "u32m10", "uint32m10": unsigned modulo-10000 integer, 2 registers
+	//  "u48m10", "uint48m10": unsigned modulo-10000 integer, 3 registers
+	//  "u64m10", "uint64m10": unsigned modulo-10000 integer, 4 registers
+	//  "s32m10", "int32m10":  signed modulo-10000 integer, 2 registers
+	//  "s48m10", "int48m10":  signed modulo-10000 integer, 3 registers
+	//  "s64m10", "int64m10":  signed modulo-10000 integer, 4 registers
+	//  "f16", "float16": 16-bit (half-precision) floating point number
+	//  "u24", "uint24":  unsigned 24-bit integer
+	//  "s24", "int24":   signed 24-bit integer
+	//  "u48", "uint48":  unsigned 48-bit integer
+	//  "s48", "int48":   signed 48-bit integer
+	//  "sm16", "sm32", "sm64": sign-magnitude integers
+	//  "qM.N", "uqM.N":  signed / unsigned Qm.n fixed-point numbers
+	//  "unix32", "unix64": seconds since 1970-01-01 as an RFC3339 timestamp
+	//  "epoch2000":      seconds since 2000-01-01 as an RFC3339 timestamp
+	//  "datetime":       6 registers, year/month/day/hour/minute/second
+	//  "datetimepacked": 3 registers, one byte each of year-2000/month/day/hour/minute/second
+	//  "duration32", "duration64": seconds as a duration string
 	Type string
+
+	// StringOptions controls how string types are decoded. When unset, strings
+	// are decoded as UTF-8 and end at the first NULL byte.
+	StringOptions *StringOptions `yaml:"stringOptions,omitempty"`
+
+	// NotAvailable lists values the device reports when it has no value for
+	// the register, e.g. 0xFFFF. A reading with one of these values has a nil
+	// value. Numbers are compared with both the decoded value and the raw
+	// register bits; "nan" matches any floating point NaN.
+	NotAvailable []interface{} `yaml:"notAvailable,omitempty"`
+
+	// NotAvailableDefaults adds the common not available values for the type
+	// (e.g. 0x8000 for s16, NaN for f32) to NotAvailable.
+	NotAvailableDefaults bool `yaml:"notAvailableDefaults,omitempty"`
+
+	// Min and Max are optional plausibility limits for numeric readings. A
+	// reading outside of the limits is handled according to RangeAction.
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+
+	// RangeAction is what to do with a reading outside of Min / Max: "drop"
+	// (the default) gives the reading a nil value, "clamp" replaces the value
+	// with the limit.
+	RangeAction string `yaml:"rangeAction,omitempty"`
+
+	// Bit, when set, is the position (0 for the least significant) of a single
+	// bit in the holding register at Address. Reads give the bit as a boolean
+	// and writes set or clear only that bit, leaving the rest of the register
+	// unchanged.
+	Bit *uint16 `yaml:"bit,omitempty"`
+
+	// TypedWrite makes holding register writes encode the written value with
+	// the device Type and Width (e.g. "21.5" for an f32) and send it with a
+	// single write multiple registers call. When false, the written value is a
+	// hex string for a single register.
+	TypedWrite bool `yaml:"typedWrite,omitempty"`
+
+	// VerifyWrite reads the written register(s) back after each write and
+	// fails the write if they do not hold the written value.
+	VerifyWrite bool `yaml:"verifyWrite,omitempty"`
+
+	// VerifyDelay is the duration to wait after a write before reading it
+	// back for VerifyWrite. Defaults to no delay.
+	VerifyDelay string `yaml:"verifyDelay,omitempty"`
+
+	// PulseDuration is how long a coil stays on for the pulse write action,
+	// unless the write data gives a duration. Defaults to 1s.
+	PulseDuration string `yaml:"pulseDuration,omitempty"`
+
+	// Writable, when false, rejects all writes to the device.
+	Writable *bool `yaml:"writable,omitempty"`
+
+	// WriteMin and WriteMax are optional limits on written values. Writes
+	// outside of the limits are rejected before anything is sent.
+	WriteMin *float64 `yaml:"writeMin,omitempty"`
+	WriteMax *float64 `yaml:"writeMax,omitempty"`
+
+	// WriteAllowed, when set, lists the only values which may be written.
+	WriteAllowed []float64 `yaml:"writeAllowed,omitempty"`
+
+	// Rate, when set, derives a rate of change from this counter register and
+	// publishes it as a second reading alongside the counter reading.
+	Rate *RateOptions `yaml:"rate,omitempty"`
+
+	// CoalesceWindow, when set, holds writes to the device for up to this
+	// duration so that writes to contiguous registers or coils on the same
+	// host and slave id can be sent in a single request. Writes are only
+	// combined when the plugin runs in parallel mode.
+	CoalesceWindow string `yaml:"coalesceWindow,omitempty"`
+
+	// DryRun, when true, validates, encodes and logs writes to the device
+	// without sending them.
+	DryRun bool `yaml:"dryRun,omitempty"`
+
+	// WritePreamble is a sequence of register writes made before each write
+	// to the device, e.g. to write a password or unlock code.
+	WritePreamble []RegisterWrite `yaml:"writePreamble,omitempty"`
+
+	// WritePostamble is a sequence of register writes made after each write
+	// to the device, e.g. to relock it. It is made whenever the preamble was.
+	WritePostamble []RegisterWrite `yaml:"writePostamble,omitempty"`
+
+	// Handshake, when set, makes each holding register write a command. After
+	// the write, a status register is polled until it reports the command done
+	// or failed.
+	Handshake *HandshakeOptions `yaml:"handshake,omitempty"`
+}
+
+// HandshakeOptions are the options for the command/status write handshake.
+type HandshakeOptions struct {
+	// StatusAddress is the holding register with the command status.
+	StatusAddress uint16 `yaml:"statusAddress"`
+
+	// Done are the status values which report the command completed.
+	Done []uint16 `yaml:"done"`
+
+	// Error are the status values which report the command failed. Any
+	// other status means the command is still in progress.
+	Error []uint16 `yaml:"error,omitempty"`
+
+	// PollInterval is the duration between status reads. Defaults to 100ms.
+	PollInterval string `yaml:"pollInterval,omitempty"`
+
+	// Timeout is how long to wait for the command to complete. Defaults to 5s.
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// GetPollInterval gets the status poll interval as a duration.
+func (options *HandshakeOptions) GetPollInterval() (time.Duration, error) {
+	if options.PollInterval == "" {
+		return 100 * time.Millisecond, nil
+	}
+	return time.ParseDuration(options.PollInterval)
+}
+
+// GetTimeout gets the command timeout as a duration.
+func (options *HandshakeOptions) GetTimeout() (time.Duration, error) {
+	if options.Timeout == "" {
+		return 5 * time.Second, nil
+	}
+	return time.ParseDuration(options.Timeout)
+}
+
+// RegisterWrite is a write of fixed values to holding registers in a write
+// preamble or postamble.
+type RegisterWrite struct {
+	// Address is the first register to write.
+	Address uint16 `yaml:"address"`
+
+	// Values are written to consecutive registers starting at Address.
+	Values []uint16 `yaml:"values"`
+
+	// Delay is the duration to wait after the write, if any.
+	Delay string `yaml:"delay,omitempty"`
+}
+
+// GetDelay gets the delay after the register write as a duration.
+func (write *RegisterWrite) GetDelay() (time.Duration, error) {
+	if write.Delay == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(write.Delay)
+}
+
+// RateOptions are the options for deriving a rate from a counter register.
+type RateOptions struct {
+	// Per is the time unit of the rate: "second" (the default), "minute" or "hour".
+	Per string `yaml:"per,omitempty"`
+
+	// Output is the name of the output for the rate reading. Defaults to the
+	// output of the device.
+	Output string `yaml:"output,omitempty"`
+}
+
+// StringOptions are the options for decoding string typed register data.
+type StringOptions struct {
+	// ByteSwap swaps the two bytes of each register before decoding.
+	ByteSwap bool `yaml:"byteSwap,omitempty"`
+
+	// Trim removes leading and trailing characters from the decoded string.
+	// Supported values are "nul", "space" and "all" (both NUL and space).
+	Trim string `yaml:"trim,omitempty"`
+
+	// ReplaceNul, when set, replaces each NUL remaining after trimming with
+	// the given string instead of ending the string at the first NUL.
+	ReplaceNul *string `yaml:"replaceNul,omitempty"`
+
+	// Encoding is the character encoding of the data. Supported values are
+	// "utf8" (the default), "ascii", "latin1", "utf16" (big endian) and "utf16le".
+	Encoding string `yaml:"encoding,omitempty"`
+}
+
+// ComputedDeviceData is the decoded yaml of an sdk.Device with the computed
+// handler. Its reading is an expression over the readings of other devices.
+type ComputedDeviceData struct {
+	// Expression is the arithmetic expression for the reading, e.g. "a + b + c".
+	Expression string `yaml:"expression,omitempty"`
+
+	// Sources maps each variable in the expression to the id or info of the
+	// device whose reading it takes.
+	Sources map[string]string `yaml:"sources,omitempty"`
+}
+
+// ComputedDeviceDataFromDevice creates a new instance of a ComputedDeviceData
+// and loads it with values from the provided SDK Device's Data field.
+func ComputedDeviceDataFromDevice(device *sdk.Device) (*ComputedDeviceData, error) {
+	var cfg ComputedDeviceData
+	if err := decodeDeviceData(device.Data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
 }
 
 // ModbusDeviceDataFromDevice creates a new instance of a ModbusDeviceData and loads
 // it with values from the provided SDK Device's Data field.
 func ModbusDeviceDataFromDevice(device *sdk.Device) (*ModbusDeviceData, error) {
 	var cfg ModbusDeviceData
-	if err := mapstructure.Decode(device.Data, &cfg); err != nil {
+	if err := decodeDeviceData(device.Data, &cfg); err != nil {
 		return nil, err
 	}
 	return &cfg, nil
 }
 
+// deviceDataAliases maps the documented alternative names of device data keys
+// to the key names.
+var deviceDataAliases = map[string]string{
+	"slave_id": "slaveId",
+}
+
+// decodeDeviceData decodes device data into result. Aliased keys are renamed
+// and keys which do not match a field, including those of nested options, are
+// an error rather than silently ignored.
+func decodeDeviceData(data map[string]interface{}, result interface{}) error {
+	resolved := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		resolved[key] = value
+	}
+	for alias, key := range deviceDataAliases {
+		value, ok := resolved[alias]
+		if !ok {
+			continue
+		}
+		for existing := range resolved {
+			if strings.EqualFold(existing, key) {
+				return fmt.Errorf("device data has both '%s' and its alias '%s'", existing, alias)
+			}
+		}
+		delete(resolved, alias)
+		resolved[key] = value
+	}
+
+	var metadata mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Metadata: &metadata,
+		Result:   result,
+		TagName:  "yaml", // Report unknown nested keys by their documented names.
+	})
+	if err != nil {
+		return err
+	}
+	if err = decoder.Decode(resolved); err != nil {
+		return err
+	}
+	if len(metadata.Unused) > 0 {
+		sort.Strings(metadata.Unused)
+		return fmt.Errorf("unknown device data key(s): %s", strings.Join(metadata.Unused, ", "))
+	}
+	return nil
+}
+
+// GetVerifyDelay gets the write verification delay as a duration.
+func (data *ModbusDeviceData) GetVerifyDelay() (time.Duration, error) {
+	if data.VerifyDelay == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(data.VerifyDelay)
+}
+
+// GetPulseDuration gets the coil pulse duration as a duration.
+func (data *ModbusDeviceData) GetPulseDuration() (time.Duration, error) {
+	if data.PulseDuration == "" {
+		return time.Second, nil
+	}
+	return time.ParseDuration(data.PulseDuration)
+}
+
+// GetCoalesceWindow gets the write coalescing window as a duration. Zero
+// means writes are not coalesced.
+func (data *ModbusDeviceData) GetCoalesceWindow() (time.Duration, error) {
+	if data.CoalesceWindow == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(data.CoalesceWindow)
+}
+
+// GetStride gets the number of registers from the start of one array value to
+// the start of the next.
+func (data *ModbusDeviceData) GetStride() uint16 {
+	if data.Stride == 0 {
+		return data.Width
+	}
+	return data.Stride
+}
+
+// GetSpan gets the number of registers from the first register of the device
+// to the last, covering every value of an array device.
+func (data *ModbusDeviceData) GetSpan() uint16 {
+	if data.Count <= 1 {
+		return data.Width
+	}
+	return (data.Count-1)*data.GetStride() + data.Width
+}
+
 // GetTimeout gets the timeout configuration as a duration.
 func (data *ModbusDeviceData) GetTimeout() (time.Duration, error) {
 	return time.ParseDuration(data.Timeout)
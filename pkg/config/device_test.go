@@ -34,6 +34,32 @@ func TestModbusDeviceDataFromDevice(t *testing.T) {
 	assert.Equal(t, "u32", cfg.Type)
 }
 
+func TestModbusDeviceDataFromDevice_StringOptions(t *testing.T) {
+	d := &sdk.Device{
+		Data: map[string]interface{}{
+			"host":    "localhost",
+			"port":    5050,
+			"address": 300,
+			"width":   8,
+			"type":    "t8",
+			"stringOptions": map[string]interface{}{
+				"byteSwap":   true,
+				"trim":       "all",
+				"replaceNul": " ",
+				"encoding":   "latin1",
+			},
+		},
+	}
+
+	cfg, err := ModbusDeviceDataFromDevice(d)
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg.StringOptions)
+	assert.Equal(t, true, cfg.StringOptions.ByteSwap)
+	assert.Equal(t, "all", cfg.StringOptions.Trim)
+	assert.Equal(t, " ", *cfg.StringOptions.ReplaceNul)
+	assert.Equal(t, "latin1", cfg.StringOptions.Encoding)
+}
+
 func TestModbusDeviceDataFromDevice_Error(t *testing.T) {
 	d := &sdk.Device{
 		Data: map[string]interface{}{
@@ -117,3 +143,76 @@ func TestModbusDeviceData_Validate4(t *testing.T) {
 	assert.Equal(t, "5s", data.Timeout)
 	assert.Equal(t, false, data.FailOnError)
 }
+
+func TestModbusDeviceData_GetSpan(t *testing.T) {
+	var tests = []struct {
+		data   ModbusDeviceData
+		stride uint16
+		span   uint16
+	}{
+		{data: ModbusDeviceData{Width: 2}, stride: 2, span: 2},
+		{data: ModbusDeviceData{Width: 2, Count: 1}, stride: 2, span: 2},
+		{data: ModbusDeviceData{Width: 2, Count: 4}, stride: 2, span: 8},
+		{data: ModbusDeviceData{Width: 2, Count: 4, Stride: 3}, stride: 3, span: 11},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.stride, tt.data.GetStride())
+		assert.Equal(t, tt.span, tt.data.GetSpan())
+	}
+}
+
+func TestModbusDeviceDataFromDevice_WriteLimits(t *testing.T) {
+	d := &sdk.Device{
+		Data: map[string]interface{}{
+			"writable":     false,
+			"writeMin":     0,
+			"writeMax":     100.5,
+			"writeAllowed": []interface{}{0, 50, 100.5},
+		},
+	}
+
+	cfg, err := ModbusDeviceDataFromDevice(d)
+	assert.NoError(t, err)
+	assert.False(t, *cfg.Writable)
+	assert.Equal(t, 0.0, *cfg.WriteMin)
+	assert.Equal(t, 100.5, *cfg.WriteMax)
+	assert.Equal(t, []float64{0, 50, 100.5}, cfg.WriteAllowed)
+}
+
+func TestModbusDeviceDataFromDevice_Strict(t *testing.T) {
+	// The documented slave_id alias.
+	d := &sdk.Device{
+		Data: map[string]interface{}{"host": "localhost", "port": 502, "slave_id": 3},
+	}
+	cfg, err := ModbusDeviceDataFromDevice(d)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cfg.SlaveID)
+	assert.Equal(t, 3, d.Data["slave_id"]) // The device data is not changed.
+
+	var tests = []struct {
+		data    map[string]interface{}
+		message string
+	}{
+		{
+			data:    map[string]interface{}{"host": "localhost", "slaveID": 1, "slave_id": 3},
+			message: "device data has both 'slaveID' and its alias 'slave_id'",
+		},
+		{
+			data:    map[string]interface{}{"host": "localhost", "adress": 1, "failonerror": true},
+			message: "unknown device data key(s): adress",
+		},
+		{
+			data: map[string]interface{}{"host": "localhost", "rate": map[string]interface{}{"per": "minute", "unit": "kWh"},
+				"writePreamble": []interface{}{map[string]interface{}{"address": 1, "value": 2}}},
+			message: "unknown device data key(s): rate.unit, writePreamble[0].value",
+		},
+	}
+	for _, tt := range tests {
+		_, err := ModbusDeviceDataFromDevice(&sdk.Device{Data: tt.data})
+		assert.EqualError(t, err, tt.message)
+	}
+
+	_, err = ComputedDeviceDataFromDevice(&sdk.Device{Data: map[string]interface{}{"expression": "a", "source": "x"}})
+	assert.EqualError(t, err, "unknown device data key(s): source")
+}
@@ -0,0 +1,332 @@
+package devices
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+)
+
+// WriteAuditEntry is the audit record of a single modbus write.
+type WriteAuditEntry struct {
+	Time         time.Time `json:"time"`
+	Device       string    `json:"device"`
+	Info         string    `json:"info"`
+	Host         string    `json:"host"`
+	Port         int       `json:"port"`
+	Unit         int       `json:"unit"`
+	Address      uint16    `json:"address"`
+	FunctionCode byte      `json:"functionCode"`
+	Payload      string    `json:"payload"`            // Hex encoded.
+	Previous     string    `json:"previous,omitempty"` // Hex encoded, empty if unknown.
+	Result       string    `json:"result"`             // "ok" or the error.
+	LatencyMs    float64   `json:"latencyMs"`
+}
+
+// auditClient is a modbus client which audits each write made through it. The
+// previous value of a write is known when the same registers or coil were read
+// through the client earlier, e.g. for a toggle or a read-modify-write.
+type auditClient struct {
+	modbus.Client
+	device     *sdk.Device
+	deviceData *config.ModbusDeviceData
+	registers  map[uint16]uint16 // Last known register values.
+	coils      map[uint16]bool   // Last known coil states.
+}
+
+// newAuditClient wraps client to audit the writes made for device.
+func newAuditClient(client modbus.Client, device *sdk.Device, deviceData *config.ModbusDeviceData) *auditClient {
+	return &auditClient{
+		Client:     client,
+		device:     device,
+		deviceData: deviceData,
+		registers:  make(map[uint16]uint16),
+		coils:      make(map[uint16]bool),
+	}
+}
+
+// ReadCoils reads coils and remembers their states.
+func (c *auditClient) ReadCoils(address, quantity uint16) ([]byte, error) {
+	results, err := c.Client.ReadCoils(address, quantity)
+	if err == nil {
+		for i := uint16(0); i < quantity && int(i/8) < len(results); i++ {
+			c.coils[address+i] = results[i/8]&(1<<(i%8)) != 0
+		}
+	}
+	return results, err
+}
+
+// ReadHoldingRegisters reads holding registers and remembers their values.
+func (c *auditClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	results, err := c.Client.ReadHoldingRegisters(address, quantity)
+	if err == nil {
+		c.rememberRegisters(address, results)
+	}
+	return results, err
+}
+
+// WriteSingleCoil writes and audits a single coil.
+func (c *auditClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	var previous []byte
+	if state, ok := c.coils[address]; ok {
+		previous = []byte{0, 0}
+		if state {
+			previous = []byte{0xff, 0}
+		}
+	}
+	start := time.Now()
+	results, err := c.Client.WriteSingleCoil(address, value)
+	c.audit(start, address, modbus.FuncCodeWriteSingleCoil, []byte{byte(value >> 8), byte(value)}, previous, err)
+	if err == nil {
+		c.coils[address] = value == 0xFF00
+	}
+	return results, err
+}
+
+// WriteMultipleCoils writes and audits multiple coils.
+func (c *auditClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	start := time.Now()
+	results, err := c.Client.WriteMultipleCoils(address, quantity, value)
+	c.audit(start, address, modbus.FuncCodeWriteMultipleCoils, value, nil, err)
+	if err == nil {
+		for i := uint16(0); i < quantity && int(i/8) < len(value); i++ {
+			c.coils[address+i] = value[i/8]&(1<<(i%8)) != 0
+		}
+	}
+	return results, err
+}
+
+// WriteSingleRegister writes and audits a single holding register.
+func (c *auditClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	payload := []byte{byte(value >> 8), byte(value)}
+	previous := c.previousRegisters(address, 1)
+	start := time.Now()
+	results, err := c.Client.WriteSingleRegister(address, value)
+	c.audit(start, address, modbus.FuncCodeWriteSingleRegister, payload, previous, err)
+	if err == nil {
+		c.rememberRegisters(address, payload)
+	}
+	return results, err
+}
+
+// WriteMultipleRegisters writes and audits multiple holding registers.
+func (c *auditClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	previous := c.previousRegisters(address, quantity)
+	start := time.Now()
+	results, err := c.Client.WriteMultipleRegisters(address, quantity, value)
+	c.audit(start, address, modbus.FuncCodeWriteMultipleRegisters, value, previous, err)
+	if err == nil {
+		c.rememberRegisters(address, value)
+	}
+	return results, err
+}
+
+// ReadWriteMultipleRegisters reads and writes holding registers, auditing the write.
+func (c *auditClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
+	previous := c.previousRegisters(writeAddress, writeQuantity)
+	start := time.Now()
+	results, err := c.Client.ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity, value)
+	c.audit(start, writeAddress, modbus.FuncCodeReadWriteMultipleRegisters, value, previous, err)
+	if err == nil {
+		c.rememberRegisters(writeAddress, value)
+		c.rememberRegisters(readAddress, results)
+	}
+	return results, err
+}
+
+// MaskWriteRegister mask writes and audits a holding register. The payload is
+// the and mask followed by the or mask.
+func (c *auditClient) MaskWriteRegister(address, andMask, orMask uint16) ([]byte, error) {
+	payload := []byte{byte(andMask >> 8), byte(andMask), byte(orMask >> 8), byte(orMask)}
+	previous := c.previousRegisters(address, 1)
+	start := time.Now()
+	results, err := c.Client.MaskWriteRegister(address, andMask, orMask)
+	c.audit(start, address, modbus.FuncCodeMaskWriteRegister, payload, previous, err)
+	if err == nil {
+		if current, ok := c.registers[address]; ok {
+			c.registers[address] = (current & andMask) | (orMask &^ andMask)
+		}
+	}
+	return results, err
+}
+
+// rememberRegisters records the register values in data starting at address.
+func (c *auditClient) rememberRegisters(address uint16, data []byte) {
+	for i := 0; i+1 < len(data); i += 2 {
+		c.registers[address+uint16(i/2)] = uint16(data[i])<<8 | uint16(data[i+1])
+	}
+}
+
+// previousRegisters gets the last known values of quantity registers starting
+// at address, or nil if any of them is unknown.
+func (c *auditClient) previousRegisters(address, quantity uint16) []byte {
+	previous := make([]byte, 0, 2*quantity)
+	for i := uint16(0); i < quantity; i++ {
+		value, ok := c.registers[address+i]
+		if !ok {
+			return nil
+		}
+		previous = append(previous, byte(value>>8), byte(value))
+	}
+	return previous
+}
+
+// audit makes the audit entry for a write which started at start.
+func (c *auditClient) audit(start time.Time, address uint16, functionCode byte, payload []byte, previous []byte, err error) {
+	auditWrite(newAuditEntry(c.device, c.deviceData, start, address, functionCode, payload, previous, err))
+}
+
+// newAuditEntry makes the audit entry for a write to device which started at start.
+func newAuditEntry(device *sdk.Device, deviceData *config.ModbusDeviceData, start time.Time,
+	address uint16, functionCode byte, payload []byte, previous []byte, err error) *WriteAuditEntry {
+	entry := &WriteAuditEntry{
+		Time:         start.UTC(),
+		Host:         deviceData.Host,
+		Port:         deviceData.Port,
+		Unit:         deviceData.SlaveID,
+		Address:      address,
+		FunctionCode: functionCode,
+		Payload:      hex.EncodeToString(payload),
+		Previous:     hex.EncodeToString(previous),
+		Result:       "ok",
+		LatencyMs:    float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if device != nil {
+		entry.Device = device.GetID()
+		entry.Info = device.Info
+	}
+	if err != nil {
+		entry.Result = err.Error()
+	}
+	return entry
+}
+
+// auditWrite logs the write audit entry and appends it to the audit file, if any.
+func auditWrite(entry *WriteAuditEntry) {
+	logEntry := log.WithFields(log.Fields{
+		"device":       entry.Device,
+		"info":         entry.Info,
+		"host":         entry.Host,
+		"port":         entry.Port,
+		"unit":         entry.Unit,
+		"address":      fmt.Sprintf("0x%x", entry.Address),
+		"functionCode": entry.FunctionCode,
+		"payload":      entry.Payload,
+		"previous":     entry.Previous,
+		"result":       entry.Result,
+		"latencyMs":    entry.LatencyMs,
+	})
+	if entry.Result == "ok" {
+		logEntry.Info("[modbus write audit]")
+	} else {
+		logEntry.Warn("[modbus write audit]")
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	if auditFile == nil {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Errorf("Failed to encode write audit entry: %v", err)
+		return
+	}
+	if err = auditFile.write(append(line, '\n')); err != nil {
+		log.Errorf("Failed to write audit file %s: %v", auditFile.path, err)
+	}
+}
+
+// auditFile is the rotating write audit file, nil when not configured.
+var auditFile *rotatingFile
+var auditMutex sync.Mutex
+
+// SetWriteAuditFile appends write audit entries as JSON lines to the file at
+// path. When the file would grow beyond maxBytes it is rotated to path.1, and
+// so on up to maxFiles rotated files. An empty path stops writing the file.
+func SetWriteAuditFile(path string, maxBytes int64, maxFiles int) error {
+	var file *rotatingFile
+	if path != "" {
+		if maxBytes <= 0 {
+			return fmt.Errorf("write audit file max bytes %d must be positive", maxBytes)
+		}
+		if maxFiles < 0 {
+			return fmt.Errorf("write audit file max files %d is negative", maxFiles)
+		}
+		file = &rotatingFile{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+		if err := file.open(); err != nil {
+			return err
+		}
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+	if auditFile != nil {
+		auditFile.file.Close()
+	}
+	auditFile = file
+	return nil
+}
+
+// rotatingFile is an append only file which is rotated at a maximum size.
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// open opens the file for appending.
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// write appends data to the file, rotating first if the file would grow
+// beyond the maximum size.
+func (f *rotatingFile) write(data []byte) error {
+	if f.size > 0 && f.size+int64(len(data)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return err
+}
+
+// rotate shifts path.n to path.n+1, dropping the oldest file, moves the file
+// to path.1 and opens a new file.
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+	if f.maxFiles == 0 {
+		os.Remove(f.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxFiles))
+		for n := f.maxFiles - 1; n >= 1; n-- {
+			os.Rename(fmt.Sprintf("%s.%d", f.path, n), fmt.Sprintf("%s.%d", f.path, n+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			log.Errorf("Failed to rotate write audit file %s: %v", f.path, err)
+		}
+	}
+	return f.open()
+}
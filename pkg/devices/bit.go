@@ -0,0 +1,104 @@
+package devices
+
+import (
+	"fmt"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+)
+
+// validateBit checks the bit configuration in the device data.
+func validateBit(deviceData *config.ModbusDeviceData, handler string) error {
+	switch handler {
+	case "holding_register", "read_only_holding_register", "input_register":
+	default:
+		return fmt.Errorf("'bit' is not supported for the %s handler", handler)
+	}
+	if *deviceData.Bit > 15 {
+		return fmt.Errorf("'bit' %d must be 0 to 15", *deviceData.Bit)
+	}
+	if deviceData.Width != 1 {
+		return fmt.Errorf("'width' must be 1 with 'bit', is %d", deviceData.Width)
+	}
+	if deviceData.TypedWrite || deviceData.Count > 1 || len(deviceData.Addresses) > 0 {
+		return fmt.Errorf("'bit' is not supported with 'typedWrite', 'count' or 'addresses'")
+	}
+	return nil
+}
+
+// unpackBit gets a bit from a single register.
+func unpackBit(bit uint16, rawReading []byte) (bool, error) {
+	if len(rawReading) != 2 {
+		return false, fmt.Errorf("bit must be read from two bytes, got %d", len(rawReading))
+	}
+	register := uint16(rawReading[0])<<8 | uint16(rawReading[1])
+	return register&(1<<bit) != 0, nil
+}
+
+// writeBit sets or clears the configured bit of a holding register with a
+// mask write register (function code 22), which leaves the other bits of the
+// register unchanged. Devices which do not support mask write register get a
+// read-modify-write instead.
+func writeBit(client modbus.Client, deviceData *config.ModbusDeviceData, value string) (err error) {
+	var set bool
+	switch value {
+	case "0", "false", "False":
+		set = false
+	case "1", "true", "True":
+		set = true
+	default:
+		return fmt.Errorf("unknown bit data %v", value)
+	}
+	if err = checkWriteState(deviceData, set); err != nil {
+		return err
+	}
+
+	register := deviceData.Address
+	mask := uint16(1) << *deviceData.Bit
+	var orMask uint16
+	if set {
+		orMask = mask
+	}
+
+	log.Debugf("Mask writing holding register 0x%x, and 0x%04x, or 0x%04x", register, ^mask, orMask)
+	_, err = client.MaskWriteRegister(register, ^mask, orMask)
+	incrementModbusCallCounter()
+	if modbusErr, ok := err.(*modbus.ModbusError); ok && modbusErr.ExceptionCode == modbus.ExceptionCodeIllegalFunction {
+		log.Infof("Mask write register not supported at 0x%x, falling back to read-modify-write", register)
+		err = readModifyWriteBit(client, register, mask, set)
+	}
+	if err != nil {
+		return err
+	}
+
+	if deviceData.VerifyWrite {
+		return verifyBit(client, deviceData, register, mask, set)
+	}
+	return nil
+}
+
+// readModifyWriteBit sets or clears the masked bit of a holding register by
+// reading the register and writing it back. Unlike a mask write, this is not
+// atomic on the device.
+func readModifyWriteBit(client modbus.Client, register uint16, mask uint16, set bool) error {
+	current, err := client.ReadHoldingRegisters(register, 1)
+	incrementModbusCallCounter()
+	if err != nil {
+		return err
+	}
+	if len(current) != 2 {
+		return fmt.Errorf("read of register 0x%x returned %d bytes", register, len(current))
+	}
+
+	value := uint16(current[0])<<8 | uint16(current[1])
+	if set {
+		value |= mask
+	} else {
+		value &^= mask
+	}
+	log.Debugf("Writing holding register 0x%x, data 0x%x", register, value)
+	_, err = client.WriteSingleRegister(register, value)
+	incrementModbusCallCounter()
+	return err
+}
@@ -0,0 +1,293 @@
+package devices
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+)
+
+// Maximum quantities for one write multiple request in the modbus spec.
+const (
+	maxWriteRegisters = 123
+	maxWriteCoils     = 1968
+)
+
+// newClient is utils.NewClient, swapped out by tests.
+var newClient = utils.NewClient
+
+// coalesceKey identifies the writes which can be sent in the same request.
+// Writes are only held together with writes which have the same window, and
+// are sent on a connection with their own timeout.
+type coalesceKey struct {
+	Host    string
+	Port    int
+	SlaveID int
+	Coil    bool
+	Window  time.Duration
+	Timeout string
+}
+
+// pendingWrite is an encoded write waiting to be sent by the coalescer.
+type pendingWrite struct {
+	device     *sdk.Device
+	deviceData *config.ModbusDeviceData
+	coil       bool
+	address    uint16
+	quantity   uint16
+	payload    []byte     // Register bytes, or 0xff00 / 0x0000 for a coil.
+	done       chan error // Receives the result of the write.
+}
+
+// writeCoalescer holds writes for their coalescing window and sends the
+// writes to contiguous registers or coils in a single request.
+type writeCoalescer struct {
+	mutex   sync.Mutex
+	pending map[coalesceKey][]*pendingWrite
+}
+
+var coalescer = &writeCoalescer{pending: make(map[coalesceKey][]*pendingWrite)}
+
+// coalescable returns true if the write should go through the coalescer.
+// Writes which read from the device (toggle, bit writes, verification and
+// handshakes) and coil pulses are always made directly.
+func coalescable(deviceData *config.ModbusDeviceData, data *sdk.WriteData) bool {
+	window, err := deviceData.GetCoalesceWindow()
+	if err != nil || window <= 0 {
+		return false
+	}
+	if deviceData.VerifyWrite || deviceData.Bit != nil || deviceData.Handshake != nil || hasSequences(deviceData) {
+		return false
+	}
+	return data.Action != "toggle" && data.Action != "pulse"
+}
+
+// validateCoalesce checks the write coalescing configuration in the device data.
+func validateCoalesce(deviceData *config.ModbusDeviceData) error {
+	window, err := deviceData.GetCoalesceWindow()
+	if err != nil {
+		return fmt.Errorf("'coalesceWindow': %v", err)
+	}
+	if window < 0 {
+		return fmt.Errorf("'coalesceWindow' %v is negative", window)
+	}
+	if window > 0 && (deviceData.VerifyWrite || deviceData.Bit != nil || deviceData.Handshake != nil || hasSequences(deviceData)) {
+		return fmt.Errorf("'coalesceWindow' is not supported with 'verifyWrite', 'bit', 'handshake', 'writePreamble' or 'writePostamble'")
+	}
+	return nil
+}
+
+// coalesceRegisterWrite encodes a holding register write and sends it through
+// the coalescer.
+func coalesceRegisterWrite(device *sdk.Device, deviceData *config.ModbusDeviceData, data *sdk.WriteData) error {
+	if err := checkWritable(deviceData); err != nil {
+		return err
+	}
+	payload, err := encodeRegisterWrite(deviceData, data)
+	if err != nil {
+		return err
+	}
+	return coalescer.submit(&pendingWrite{
+		device:     device,
+		deviceData: deviceData,
+		address:    deviceData.Address,
+		quantity:   uint16(len(payload) / 2), // Two bytes per register.
+		payload:    payload,
+	})
+}
+
+// coalesceCoilWrite checks a coil write and sends it through the coalescer.
+func coalesceCoilWrite(device *sdk.Device, deviceData *config.ModbusDeviceData, data *sdk.WriteData) error {
+	if err := checkWritable(deviceData); err != nil {
+		return err
+	}
+	state, err := coilWriteState(deviceData, data)
+	if err != nil {
+		return err
+	}
+	payload := []byte{0x00, 0x00}
+	if state {
+		payload = []byte{0xff, 0x00}
+	}
+	return coalescer.submit(&pendingWrite{
+		device:     device,
+		deviceData: deviceData,
+		coil:       true,
+		address:    deviceData.Address,
+		quantity:   1,
+		payload:    payload,
+	})
+}
+
+// submit adds the write to the pending writes for its key and waits for its
+// result. The first pending write starts the coalescing window.
+func (c *writeCoalescer) submit(write *pendingWrite) error {
+	window, err := write.deviceData.GetCoalesceWindow()
+	if err != nil {
+		return err
+	}
+	key := coalesceKey{
+		Host:    write.deviceData.Host,
+		Port:    write.deviceData.Port,
+		SlaveID: write.deviceData.SlaveID,
+		Coil:    write.coil,
+		Window:  window,
+		Timeout: write.deviceData.Timeout,
+	}
+	write.done = make(chan error, 1)
+
+	c.mutex.Lock()
+	c.pending[key] = append(c.pending[key], write)
+	if len(c.pending[key]) == 1 {
+		time.AfterFunc(window, func() { c.flush(key) })
+	}
+	c.mutex.Unlock()
+
+	return <-write.done
+}
+
+// flush sends the pending writes for key on one connection.
+func (c *writeCoalescer) flush(key coalesceKey) {
+	c.mutex.Lock()
+	batch := c.pending[key]
+	delete(c.pending, key)
+	c.mutex.Unlock()
+
+	client, handler, err := newClient(batch[0].deviceData)
+	if err != nil {
+		for _, write := range batch {
+			write.done <- err
+		}
+		return
+	}
+	defer handler.Close()
+	sendCoalesced(client, batch)
+}
+
+// sendCoalesced sends each run of contiguous writes in the batch as a single
+// request, reads back the devices which were written, and gives every write
+// the result of its request.
+func sendCoalesced(client modbus.Client, batch []*pendingWrite) {
+	results := make(map[*pendingWrite]error, len(batch))
+	for _, run := range coalesceRuns(batch) {
+		err := sendRun(client, run)
+		for _, write := range run {
+			results[write] = err
+		}
+	}
+
+	// Each device written is read once, on the connection of the batch.
+	read := make(map[*sdk.Device]bool)
+	for _, write := range batch {
+		if results[write] == nil && write.device != nil && !read[write.device] {
+			read[write.device] = true
+			readAfterWrite(client, write.device, write.deviceData, write.coil)
+		}
+	}
+	for _, write := range batch {
+		write.done <- results[write]
+	}
+}
+
+// coalesceRuns splits the batch into runs of contiguous writes within the
+// request size limit. A write which overlaps an earlier write in the batch, and
+// every write after it, are sent after the earlier writes, so that overlapping
+// writes are made in the order they were submitted.
+func coalesceRuns(batch []*pendingWrite) (runs [][]*pendingWrite) {
+	for len(batch) > 0 {
+		n := 1
+		for n < len(batch) && !overlapsAny(batch[n], batch[:n]) {
+			n++
+		}
+		runs = append(runs, contiguousRuns(batch[:n])...)
+		batch = batch[n:]
+	}
+	return
+}
+
+// overlapsAny returns true if the write is to a register or coil of any of the
+// writes.
+func overlapsAny(write *pendingWrite, writes []*pendingWrite) bool {
+	start, end := uint32(write.address), uint32(write.address)+uint32(write.quantity)
+	for _, w := range writes {
+		if start < uint32(w.address)+uint32(w.quantity) && uint32(w.address) < end {
+			return true
+		}
+	}
+	return false
+}
+
+// contiguousRuns sorts writes which do not overlap by address and splits them
+// into runs of contiguous writes within the request size limit.
+func contiguousRuns(writes []*pendingWrite) (runs [][]*pendingWrite) {
+	sorted := append([]*pendingWrite(nil), writes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].address < sorted[j].address })
+
+	var end uint32      // The address after the last run.
+	var quantity uint16 // The quantity of the last run.
+	for _, write := range sorted {
+		limit := uint16(maxWriteRegisters)
+		if write.coil {
+			limit = maxWriteCoils
+		}
+		last := len(runs) - 1
+		if last >= 0 && uint32(write.address) == end && quantity+write.quantity <= limit {
+			runs[last] = append(runs[last], write)
+		} else {
+			runs = append(runs, []*pendingWrite{write})
+			quantity = 0
+		}
+		end = uint32(write.address) + uint32(write.quantity)
+		quantity += write.quantity
+	}
+	return
+}
+
+// sendRun sends a run of contiguous writes in one request. A run of one write
+// is sent as it would be without coalescing.
+func sendRun(client modbus.Client, run []*pendingWrite) (err error) {
+	address := run[0].address
+	var quantity uint16
+	var payload []byte
+	for _, write := range run {
+		quantity += write.quantity
+		payload = append(payload, write.payload...)
+	}
+
+	start := time.Now()
+	var functionCode byte
+	switch {
+	case run[0].coil && len(run) == 1:
+		functionCode = modbus.FuncCodeWriteSingleCoil
+		_, err = client.WriteSingleCoil(address, uint16(payload[0])<<8|uint16(payload[1]))
+	case run[0].coil:
+		functionCode = modbus.FuncCodeWriteMultipleCoils
+		packed := make([]byte, (quantity+7)/8)
+		for i, write := range run {
+			if write.payload[0] != 0 {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		_, err = client.WriteMultipleCoils(address, quantity, packed)
+	case len(run) == 1 && !run[0].deviceData.TypedWrite:
+		functionCode = modbus.FuncCodeWriteSingleRegister
+		_, err = client.WriteSingleRegister(address, uint16(payload[0])<<8|uint16(payload[1]))
+	default:
+		functionCode = modbus.FuncCodeWriteMultipleRegisters
+		_, err = client.WriteMultipleRegisters(address, quantity, payload)
+	}
+	incrementModbusCallCounter()
+	log.Debugf("[modbus call]: coalesced write of %d device(s) (function code %d) at 0x%x, count %d, data 0x%x, err: %v",
+		len(run), functionCode, address, quantity, payload, err)
+
+	for _, write := range run {
+		auditWrite(newAuditEntry(write.device, write.deviceData, start, write.address, functionCode, write.payload, nil, err))
+	}
+	return err
+}
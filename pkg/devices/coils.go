@@ -2,6 +2,7 @@ package devices
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/goburrow/modbus"
 	log "github.com/sirupsen/logrus"
@@ -15,6 +16,7 @@ var CoilsHandler = sdk.DeviceHandler{
 	Name:     "coil",
 	BulkRead: bulkReadCoils,
 	Write:    writeCoils,
+	Listen:   listenForWrites,
 }
 
 // ReadOnlyCoilsHandler is a handler that should be used for all devices/outputs
@@ -31,6 +33,7 @@ func bulkReadCoils(devices []*sdk.Device) (readContexts []*sdk.ReadContext, err
 
 	// Call SetupBulkRead in case it's not setup, then get the bulk read map for coils.
 	SetupBulkRead()
+	defer func() { readContexts, err = endBulkRead("coil", readContexts, err) }()
 	bulkReadMap, keyOrder, err := GetBulkReadMap("coil")
 	if err != nil {
 		return
@@ -110,26 +113,164 @@ func writeCoils(device *sdk.Device, data *sdk.WriteData) (err error) {
 	if err != nil {
 		return err
 	}
+	defer handler.Close()
+	if isDryRun(deviceData) {
+		dryRunData := dryRunDeviceData(deviceData)
+		return writeCoilData(newDryRunClient(*client, device, dryRunData), dryRunData, data)
+	}
+	if coalescable(deviceData, data) {
+		// Coalesced writes are read back on the connection of their batch.
+		return coalesceCoilWrite(device, deviceData, data)
+	}
+	if err = writeCoilData(newAuditClient(*client, device, deviceData), deviceData, data); err != nil {
+		return err
+	}
 
-	// Pull out the data to send on the wire from data.Data.
-	modbusData := data.Data
+	// Publish the written value without waiting for the next read.
+	readAfterWrite(*client, device, deviceData, true)
+	return nil
+}
+
+// writeCoilData writes data to the coil of a device with the given client.
+// The toggle action writes the inverse of the current coil state and the
+// pulse action turns the coil on, then off again after a duration. Any other
+// action writes the state in the data.
+func writeCoilData(client modbus.Client, deviceData *config.ModbusDeviceData, data *sdk.WriteData) (err error) {
+	if err = checkWritable(deviceData); err != nil {
+		return err
+	}
+
+	// The write preamble is made before the first write and the postamble after.
+	if hasSequences(deviceData) {
+		sequence := newSequenceClient(client, deviceData)
+		defer func() { err = sequence.finish(err) }()
+		client = sequence
+	}
+
+	switch data.Action {
+	case "toggle":
+		return toggleCoil(client, deviceData)
+	case "pulse":
+		return pulseCoil(client, deviceData, string(data.Data))
+	}
+
+	state, err := coilWriteState(deviceData, data)
+	if err != nil {
+		return err
+	}
+	return writeCoil(client, deviceData, state)
+}
+
+// coilWriteState gets the coil state from the write data and checks it against
+// the write limits.
+func coilWriteState(deviceData *config.ModbusDeviceData, data *sdk.WriteData) (state bool, err error) {
 	// Translate the data. For whatever reason, the modbus interface wants 0
 	// for false and FF00 for true.
-	dataString := string(modbusData)
-	var coilData uint16
+	dataString := string(data.Data)
 	switch dataString {
 	case "0", "false", "False":
-		coilData = 0
+		state = false
 	case "1", "true", "True":
-		coilData = 0xFF00
+		state = true
 	default:
-		return fmt.Errorf("unknown coil data %v", coilData)
+		return false, fmt.Errorf("unknown coil data %v", dataString)
+	}
+	if err = checkWriteState(deviceData, state); err != nil {
+		return false, err
+	}
+	return state, nil
+}
+
+// writeCoil writes the coil state, verifying it if configured.
+func writeCoil(client modbus.Client, deviceData *config.ModbusDeviceData, state bool) (err error) {
+	var coilData uint16
+	if state {
+		coilData = 0xFF00
 	}
 
 	// Write the coil data to the requested address.
 	log.Debugf("Writing coil 0x%x, data 0x%x", deviceData.Address, coilData)
-	_, err = (*client).WriteSingleCoil(deviceData.Address, coilData)
-	handler.Close()
+	_, err = client.WriteSingleCoil(deviceData.Address, coilData)
+	incrementModbusCallCounter()
+	if err != nil {
+		return err
+	}
+
+	if deviceData.VerifyWrite {
+		return verifyCoil(client, deviceData, deviceData.Address, state)
+	}
+	return nil
+}
+
+// toggleCoil reads the coil and writes the inverse state.
+func toggleCoil(client modbus.Client, deviceData *config.ModbusDeviceData) (err error) {
+	results, err := client.ReadCoils(deviceData.Address, 1)
 	incrementModbusCallCounter()
-	return err
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no data reading coil 0x%x", deviceData.Address)
+	}
+	state := results[0]&0x01 != 0
+	if err = checkWriteState(deviceData, !state); err != nil {
+		return err
+	}
+	log.Debugf("Toggling coil 0x%x from %v", deviceData.Address, state)
+	return writeCoil(client, deviceData, !state)
+}
+
+// pulseOffAttempts is the number of times to try turning a pulsed coil off.
+const pulseOffAttempts = 3
+
+// maxPulseDuration is the longest pulse which can be given in the write data.
+const maxPulseDuration = time.Minute
+
+// pulseCoil turns the coil on, waits for the duration (or the configured pulse
+// duration when empty) and turns it off. The duration in the write data can be
+// at most maxPulseDuration. Unless the device rejected the on write with an
+// exception, the off write is always made, retrying on failure, since a failed
+// on write (e.g. a response timeout) may still have turned the coil on. The
+// write runs to completion even if the write transaction times out or the
+// caller goes away.
+func pulseCoil(client modbus.Client, deviceData *config.ModbusDeviceData, duration string) (err error) {
+	pulse, err := deviceData.GetPulseDuration()
+	if duration != "" {
+		pulse, err = time.ParseDuration(duration)
+		if err == nil && pulse > maxPulseDuration {
+			return fmt.Errorf("pulse duration %v is longer than %v", pulse, maxPulseDuration)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid pulse duration: %v", err)
+	}
+	if pulse <= 0 {
+		return fmt.Errorf("pulse duration %v must be positive", pulse)
+	}
+	// Only the on write is checked. The coil is always turned off again.
+	if err = checkWriteState(deviceData, true); err != nil {
+		return err
+	}
+
+	log.Debugf("Pulsing coil 0x%x for %v", deviceData.Address, pulse)
+	_, onErr := client.WriteSingleCoil(deviceData.Address, 0xFF00)
+	incrementModbusCallCounter()
+	if onErr != nil {
+		if _, exception := onErr.(*modbus.ModbusError); exception {
+			return onErr // The device did not turn the coil on.
+		}
+		log.Errorf("Failed to turn on pulsed coil 0x%x, turning it off: %v", deviceData.Address, onErr)
+	} else {
+		sleep(pulse)
+	}
+
+	for attempt := 1; attempt <= pulseOffAttempts; attempt++ {
+		err = writeCoil(client, deviceData, false)
+		if err == nil {
+			return onErr
+		}
+		log.Errorf("Failed to turn off pulsed coil 0x%x, attempt %d of %d: %v",
+			deviceData.Address, attempt, pulseOffAttempts, err)
+	}
+	return fmt.Errorf("coil 0x%x may still be on, failed to end pulse: %v", deviceData.Address, err)
 }
@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
 	"sync"
 
 	"github.com/goburrow/modbus"
-	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
 	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
 	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
@@ -26,18 +26,17 @@ func GetModbusDeviceDataAndClient(device *sdk.Device) (
 	modbusDeviceData *config.ModbusDeviceData, client *modbus.Client, handler *modbus.TCPClientHandler, err error) {
 
 	// Pull the modbus configuration out of the device Data fields.
-	var deviceData config.ModbusDeviceData
-	err = mapstructure.Decode(device.Data, &deviceData)
+	deviceData, err := config.ModbusDeviceDataFromDevice(device)
 	if err != nil {
 		return
 	}
 
 	// Create the modbus client from the configuration data.
-	cli, handler, err := utils.NewClient(&deviceData)
+	cli, handler, err := newClient(deviceData)
 	if err != nil {
 		return
 	}
-	return &deviceData, &cli, handler, nil
+	return deviceData, &cli, handler, nil
 }
 
 // GetBulkReadClient gets the modbus client and device data for the
@@ -91,11 +90,59 @@ func UnpackCoilReading(output *output.Output, rawReading []byte, startAddress ui
 	// issue and the error should be noted.
 }
 
+// ReadingOptions are the per-device options for unpacking a reading.
+type ReadingOptions struct {
+	// StringOptions decode string types when set.
+	StringOptions *utils.StringOptions
+
+	// Bit, when set, makes the reading this bit of the register as a boolean.
+	Bit *uint16
+
+	// NotAvailable are the values which give a reading with a nil value.
+	NotAvailable []utils.Sentinel
+}
+
+// GetReadingOptions gets the options for unpacking the readings of a device.
+func GetReadingOptions(deviceData *config.ModbusDeviceData) (opts ReadingOptions, err error) {
+	opts.StringOptions = getStringOptions(deviceData)
+	opts.Bit = deviceData.Bit
+	opts.NotAvailable, err = GetNotAvailable(deviceData)
+	return
+}
+
+// getStringOptions gets the string options of the device data, if any.
+func getStringOptions(deviceData *config.ModbusDeviceData) *utils.StringOptions {
+	if deviceData.StringOptions == nil {
+		return nil
+	}
+	return &utils.StringOptions{
+		ByteSwap:   deviceData.StringOptions.ByteSwap,
+		Trim:       deviceData.StringOptions.Trim,
+		ReplaceNul: deviceData.StringOptions.ReplaceNul,
+		Encoding:   deviceData.StringOptions.Encoding,
+	}
+}
+
 // UnpackReading is a wrapper for CastToType and MakeReading.
 func UnpackReading(output *output.Output, typeName string, rawReading []byte, failOnErr bool) (reading *output.Reading, err error) {
+	return UnpackReadingWithOptions(output, typeName, ReadingOptions{}, rawReading, failOnErr)
+}
+
+// UnpackReadingWithOptions is UnpackReading with the reading options of a
+// device. String types are decoded with the string options, if any, bit
+// devices get their bit as a boolean, and not available values get a nil
+// Reading.Value.
+func UnpackReadingWithOptions(output *output.Output, typeName string, opts ReadingOptions, rawReading []byte, failOnErr bool) (reading *output.Reading, err error) {
 
 	// Cast the raw reading value to the specified output type
-	data, err := utils.CastToType(typeName, rawReading)
+	var data interface{}
+	if opts.Bit != nil {
+		data, err = unpackBit(*opts.Bit, rawReading)
+	} else if opts.StringOptions != nil && utils.IsStringType(typeName) {
+		data, err = utils.DecodeString(rawReading, opts.StringOptions)
+	} else {
+		data, err = utils.CastToType(typeName, rawReading)
+	}
 	if err != nil {
 		// Make a reading with a nil Reading.Value.
 		reading, _ = output.MakeReading(nil)
@@ -106,9 +153,87 @@ func UnpackReading(output *output.Output, typeName string, rawReading []byte, fa
 		return reading, nil // No reading.
 	}
 
+	// Values which mean "not available" get a nil Reading.Value, the same as a
+	// read which is out of bounds.
+	if utils.IsNotAvailable(data, rawReading, opts.NotAvailable) {
+		log.Debugf("Not available value for typeName: %v, rawReading: %x", typeName, rawReading)
+		return output.MakeReading(nil)
+	}
+
 	return output.MakeReading(data)
 }
 
+// unpackDeviceReading unpacks a reading with the options of the device data.
+func unpackDeviceReading(output *output.Output, deviceData *config.ModbusDeviceData, rawReading []byte, failOnErr bool) (*output.Reading, error) {
+	opts, err := GetReadingOptions(deviceData)
+	if err != nil {
+		return nil, err
+	}
+	return UnpackReadingWithOptions(output, deviceData.Type, opts, rawReading, failOnErr)
+}
+
+// UnpackArrayReadings unpacks a reading for each value of an array device from
+// the results of a bulk read. Each reading has its index in the context. When
+// the results do not cover the array, each reading has a nil value.
+func UnpackArrayReadings(theOutput *output.Output, device *sdk.Device, deviceData *config.ModbusDeviceData,
+	read *ModbusBulkRead, failOnErr bool) (readings []*output.Reading, err error) {
+	return unpackArrayReadings(theOutput, device, deviceData, read, failOnErr, true)
+}
+
+// unpackArrayReadings is UnpackArrayReadings for readings which are made in
+// the read cycle or, when inCycle is false, outside of it.
+func unpackArrayReadings(theOutput *output.Output, device *sdk.Device, deviceData *config.ModbusDeviceData,
+	read *ModbusBulkRead, failOnErr bool, inCycle bool) (readings []*output.Reading, err error) {
+
+	startDataOffset := 2 * int(deviceData.Address-read.StartRegister) // Two bytes per register.
+	endDataOffset := startDataOffset + 2*int(deviceData.GetSpan())
+	inBounds := endDataOffset <= len(read.ReadResults)
+	if !inBounds {
+		if failOnErr {
+			return nil, fmt.Errorf("Bounds check failure. startDataOffset: %v, endDataOffset: %v, readResultsLength: %v",
+				startDataOffset, endDataOffset, len(read.ReadResults))
+		}
+		log.Errorf("No data. Attempt to read beyond bounds. startDataOffset: %v, endDataOffset: %v, readResultsLength: %v",
+			startDataOffset, endDataOffset, len(read.ReadResults))
+	}
+
+	opts, err := GetReadingOptions(deviceData)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(deviceData.Count); i++ {
+		var reading *output.Reading
+		if inBounds {
+			start := startDataOffset + 2*i*int(deviceData.GetStride())
+			rawReading := read.ReadResults[start : start+2*int(deviceData.Width)]
+			reading, err = UnpackReadingWithOptions(theOutput, deviceData.Type, opts, rawReading, failOnErr)
+			if err != nil {
+				return nil, err
+			}
+			checkRange(device, deviceData, reading, inCycle)
+		} else {
+			reading, err = theOutput.MakeReading(nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		readings = append(readings, reading.WithContext(map[string]string{"index": strconv.Itoa(i)}))
+	}
+	return readings, nil
+}
+
+// GetNotAvailable gets the parsed not available values for the device data,
+// including the defaults for its type when enabled.
+func GetNotAvailable(deviceData *config.ModbusDeviceData) (sentinels []utils.Sentinel, err error) {
+	list := deviceData.NotAvailable
+	if deviceData.NotAvailableDefaults {
+		if t := utils.LookupType(deviceData.Type); t != nil {
+			list = append(append([]interface{}{}, list...), t.NotAvailable...)
+		}
+	}
+	return utils.ParseSentinels(list)
+}
+
 // ModbusBulkReadKey corresponds to a Modbus Device / Connection.
 // We will need one or more bulk reads per key entry.
 type ModbusBulkReadKey struct {
@@ -174,11 +299,65 @@ func NewModbusBulkRead(device *sdk.Device, startRegister uint16, registerCount u
 	return
 }
 
+// hasDevice returns true if the device is already associated with the read.
+func (read *ModbusBulkRead) hasDevice(device *sdk.Device) bool {
+	for _, d := range read.Devices {
+		if d == device {
+			return true
+		}
+	}
+	return false
+}
+
+// gatherRegisters gets the raw data for each of the addresses from the
+// results of the reads, in the order of the addresses. ok is false if any
+// register is missing from the results.
+func gatherRegisters(reads []*ModbusBulkRead, addresses []uint16) (rawReading []byte, ok bool) {
+	for _, address := range addresses {
+		found := false
+		for _, read := range reads {
+			if address < read.StartRegister {
+				continue
+			}
+			offset := 2 * int(address-read.StartRegister) // Two bytes per register.
+			if offset+2 <= len(read.ReadResults) {
+				rawReading = append(rawReading, read.ReadResults[offset:offset+2]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return rawReading, true
+}
+
 // ModbusDevice is an intermediate struct for sorting ModbusBulkReadKey.
 type ModbusDevice struct {
 	Host     string
 	Port     int
 	Register uint16
+	// Part is 0 for a device with a contiguous address and width, and one more
+	// than the index into addresses for a device with non-contiguous addresses.
+	Part int
+	// Index is the index of the device in the sorted devices, so that devices
+	// on the same register (e.g. bits of one register) each have an entry.
+	Index int
+}
+
+// deviceRegisters identifies the registers a device reads and how they are
+// decoded. Devices with the same registers are configured more than once.
+type deviceRegisters struct {
+	Host      string
+	Port      int
+	SlaveID   int
+	Address   uint16
+	Addresses string
+	Width     uint16
+	Count     uint16
+	Type      string
+	Bit       int // -1 for no bit.
 }
 
 // SortDevices sorts the device list.
@@ -192,27 +371,60 @@ func SortDevices(devices []*sdk.Device) (
 		return nil, nil, nil // Nothing to sort. Could arguably fail here.
 	}
 	deviceMap = make(map[ModbusDevice]*sdk.Device)
+	configured := make(map[deviceRegisters]*sdk.Device)
 
 	// For each device.
 	for i := 0; i < len(devices); i++ {
 		device := devices[i]
 
 		// Deserialize the modbus configuration.
-		var deviceData config.ModbusDeviceData
-		err = mapstructure.Decode(device.Data, &deviceData)
+		var deviceData *config.ModbusDeviceData
+		deviceData, err = config.ModbusDeviceDataFromDevice(device)
 		if err != nil {
 			return nil, nil, err
 		}
 
+		registers := deviceRegisters{
+			Host:      deviceData.Host,
+			Port:      deviceData.Port,
+			SlaveID:   deviceData.SlaveID,
+			Address:   deviceData.Address,
+			Addresses: fmt.Sprint(deviceData.Addresses),
+			Width:     deviceData.Width,
+			Count:     deviceData.Count,
+			Type:      deviceData.Type,
+			Bit:       -1,
+		}
+		if deviceData.Bit != nil {
+			registers.Bit = int(*deviceData.Bit)
+		}
+		if other, ok := configured[registers]; ok {
+			log.Warnf("Duplicate modbus device configured. Devices %q and %q, Host: %v, Port: %v, Register: %v",
+				other.Info, device.Info, deviceData.Host, deviceData.Port, deviceData.Address)
+		}
+		configured[registers] = device
+
 		key := ModbusDevice{
 			Host:     deviceData.Host,
 			Port:     deviceData.Port,
 			Register: deviceData.Address,
+			Index:    i,
 		}
 
 		// Add to locals.
-		sorted = append(sorted, key)
-		deviceMap[key] = device
+		if len(deviceData.Addresses) == 0 {
+			sorted = append(sorted, key)
+			deviceMap[key] = device
+			continue
+		}
+
+		// One entry per register so that the bulk reads cover every address.
+		for j, address := range deviceData.Addresses {
+			key.Register = address
+			key.Part = j + 1
+			sorted = append(sorted, key)
+			deviceMap[key] = device
+		}
 	} // end for each device
 
 	// Sort / trace.
@@ -232,9 +444,12 @@ func SortDevices(devices []*sdk.Device) (
 		} else if sorted[i].Register > sorted[j].Register {
 			return false
 		}
-		log.Errorf("Duplicate modbus device configured. Host: %v, Port: %v, Register: %v",
-			sorted[i].Host, sorted[i].Port, sorted[i].Register)
-		return true
+		if sorted[i].Part < sorted[j].Part {
+			return true
+		} else if sorted[i].Part > sorted[j].Part {
+			return false
+		}
+		return sorted[i].Index < sorted[j].Index
 	})
 
 	return
@@ -267,8 +482,8 @@ func MapBulkRead(devices []*sdk.Device, isCoil bool) (
 		// Create the key for this device from the device data.
 		device := sortedDevices[sorted[i]]
 		log.Debugf("--- next synse device: %v", device)
-		var deviceData config.ModbusDeviceData
-		err = mapstructure.Decode(device.Data, &deviceData)
+		var deviceData *config.ModbusDeviceData
+		deviceData, err = config.ModbusDeviceDataFromDevice(device)
 		if err != nil {
 			// Hard failure on configuration issue.
 			log.Errorf(
@@ -298,7 +513,12 @@ func MapBulkRead(devices []*sdk.Device, isCoil bool) (
 		log.Debugf("len(keyValues): %v", len(keyValues))
 
 		deviceDataAddress := deviceData.Address
-		deviceDataWidth := deviceData.Width
+		deviceDataWidth := deviceData.GetSpan() // All values of an array device.
+		if sorted[i].Part > 0 {
+			// One register of a device with non-contiguous addresses.
+			deviceDataAddress = sorted[i].Register
+			deviceDataWidth = 1
+		}
 
 		log.Debugf("deviceDataAddress: 0x%04x", deviceDataAddress)
 		log.Debugf("deviceDataWidth: %d", deviceDataWidth)
@@ -327,8 +547,14 @@ func MapBulkRead(devices []*sdk.Device, isCoil bool) (
 
 			if newRegisterCount <= key.MaximumRegisterCount {
 				log.Debugf("read fits in existing. newRegisterCount: %v", newRegisterCount)
-				lastRead.RegisterCount = newRegisterCount
-				lastRead.Devices = append(lastRead.Devices, device)
+				// Registers inside the existing read must not shrink it.
+				if newRegisterCount > lastRead.RegisterCount {
+					lastRead.RegisterCount = newRegisterCount
+				}
+				// A device with non-contiguous addresses is only added once per read.
+				if sorted[i].Part == 0 || !lastRead.hasDevice(device) {
+					lastRead.Devices = append(lastRead.Devices, device)
+				}
 			} else {
 				// Add a new read.
 				log.Debugf("read does not fit in existing. newRegisterCount: %v", newRegisterCount)
@@ -363,6 +589,15 @@ func DumpBulkReadMap(bulkReadMap map[ModbusBulkReadKey][]*ModbusBulkRead, keyOrd
 
 // MapBulkReadData maps the data read over modbus to the device read contexts.
 func MapBulkReadData(bulkReadMap map[ModbusBulkReadKey][]*ModbusBulkRead, keyOrder []ModbusBulkReadKey) (
+	readContexts []*sdk.ReadContext, err error) {
+	return mapBulkReadData(bulkReadMap, keyOrder, true)
+}
+
+// mapBulkReadData maps the data read over modbus to the device read contexts.
+// Data read outside of the read cycle (inCycle is false) does not count range
+// violations and has no rate readings, so that the state kept between read
+// cycles is only updated by the read cycle.
+func mapBulkReadData(bulkReadMap map[ModbusBulkReadKey][]*ModbusBulkRead, keyOrder []ModbusBulkReadKey, inCycle bool) (
 	readContexts []*sdk.ReadContext, err error) {
 	// This map tells us if we have already created a read context for this
 	// device and output. We can hit the same device and output more than once in
@@ -384,8 +619,7 @@ func MapBulkReadData(bulkReadMap map[ModbusBulkReadKey][]*ModbusBulkRead, keyOrd
 				readings := []*output.Reading{}
 
 				// Get address and width.
-				var deviceData config.ModbusDeviceData
-				err := mapstructure.Decode(device.Data, &deviceData)
+				deviceData, err := config.ModbusDeviceDataFromDevice(device)
 				if err != nil { // This is a configuration issue.
 					log.Errorf(
 						"MapBulkReadData failed parsing device at:[%v], device: %#v",
@@ -402,8 +636,42 @@ func MapBulkReadData(bulkReadMap map[ModbusBulkReadKey][]*ModbusBulkRead, keyOrd
 
 				readResults := read.ReadResults // Raw byte results from modbus call.
 
+				// Array devices have a reading for each value.
+				if deviceData.Count > 1 && !read.IsCoil {
+					readings, err = unpackArrayReadings(theOutput, device, deviceData, read, k.FailOnError, inCycle)
+					if err != nil {
+						return nil, err
+					}
+					accountedFor[device] = theOutput
+					readContext := sdk.NewReadContext(device, readings)
+					readContexts = append(readContexts, readContext)
+					log.Debugf("Appending readContext: %#v, device: %v", readContext, device)
+					continue // Next device.
+				}
+
 				var reading *output.Reading
-				if read.IsCoil {
+				if len(deviceData.Addresses) > 0 && !read.IsCoil {
+					if _, done := accountedFor[device]; done {
+						continue // Registers from all reads were gathered the first time.
+					}
+					accountedFor[device] = theOutput
+
+					rawReading, ok := gatherRegisters(v, deviceData.Addresses)
+					if !ok {
+						if k.FailOnError {
+							return nil, fmt.Errorf("No data for addresses %v", deviceData.Addresses)
+						}
+						log.Errorf("No data for addresses %v", deviceData.Addresses)
+						reading, err = theOutput.MakeReading(nil)
+					} else {
+						log.Debugf("rawReading: len: %v, %x", len(rawReading), rawReading)
+						reading, err = unpackDeviceReading(theOutput, deviceData, rawReading, k.FailOnError)
+					}
+					if err != nil {
+						return nil, err
+					}
+					checkRange(device, deviceData, reading, inCycle)
+				} else if read.IsCoil {
 					reading, err = UnpackCoilReading(theOutput, read.ReadResults, read.StartRegister, deviceDataAddress, k.FailOnError)
 					if err != nil {
 						return nil, err
@@ -441,14 +709,26 @@ func MapBulkReadData(bulkReadMap map[ModbusBulkReadKey][]*ModbusBulkRead, keyOrd
 					rawReading := readResults[startDataOffset:endDataOffset]
 					log.Debugf("rawReading: len: %v, %x", len(rawReading), rawReading)
 
-					reading, err = UnpackReading(theOutput, deviceData.Type, rawReading, k.FailOnError)
+					reading, err = unpackDeviceReading(theOutput, deviceData, rawReading, k.FailOnError)
 					if err != nil {
 						return nil, err
 					}
+					checkRange(device, deviceData, reading, inCycle)
 				}
 				log.Debugf("Appending reading: %#v, device: %v, output: %#v", reading, device, theOutput)
 				readings = append(readings, reading)
 
+				// Counters configured for a rate get a second reading.
+				if deviceData.Rate != nil && !read.IsCoil && inCycle {
+					var rateReading *output.Reading
+					rateReading, err = makeRateReading(device, deviceData, theOutput, reading)
+					if err != nil {
+						return nil, err
+					}
+					log.Debugf("Appending rate reading: %#v, device: %v", rateReading, device)
+					readings = append(readings, rateReading)
+				}
+
 				// Add to accounted for.
 				accountedFor[device] = theOutput
 
@@ -500,11 +780,13 @@ func incrementModbusCallCounter() {
 
 // bulkReadManager aggregates devices for bulk read.
 type bulkReadManager struct {
-	devices        []*sdk.Device // A slice of all devices.
-	coilDevices    []*sdk.Device // A slice of all coil devices.
-	holdingDevices []*sdk.Device // A slice of all holding register devices.
-	inputDevices   []*sdk.Device // A slice of all input register devices.
-	setupCompleted bool          // true once all setup is completed and we can perform bulk reads.
+	devices         []*sdk.Device     // A slice of all devices.
+	coilDevices     []*sdk.Device     // A slice of all coil devices.
+	holdingDevices  []*sdk.Device     // A slice of all holding register devices.
+	inputDevices    []*sdk.Device     // A slice of all input register devices.
+	computedDevices []*computedDevice // A slice of all computed devices.
+	cycle           *readCycle        // The read cycle for evaluating computed devices.
+	setupCompleted  bool              // true once all setup is completed and we can perform bulk reads.
 
 	coilBulkReadMap map[ModbusBulkReadKey][]*ModbusBulkRead // Mapped bulk reads for coils.
 	coilKeyOrder    []ModbusBulkReadKey                     // Order of the keys to traverse the coilBulkReadMap.
@@ -558,6 +840,16 @@ func (brm *bulkReadManager) addModbusDevice(d *sdk.Device) (err error) {
 		return
 	}
 
+	if d.Handler == "computed" {
+		var c *computedDevice
+		c, err = newComputedDevice(d)
+		if err != nil {
+			return
+		}
+		brm.computedDevices = append(brm.computedDevices, c)
+		return
+	}
+
 	return fmt.Errorf("Unknown device handler %s", d.Handler)
 }
 
@@ -607,6 +899,10 @@ func (brm *bulkReadManager) setup() (err error) {
 	log.Info("inputBulkReadMap:")
 	DumpBulkReadMap(brm.inputBulkReadMap, brm.inputKeyOrder)
 
+	// Computed device sources can only be checked once all devices are known.
+	checkComputedSources(brm.computedDevices, brm.devices)
+	brm.cycle = newReadCycle(brm.computedDevices, brm.mapIDs())
+
 	brm.setupCompleted = true
 	log.Infof("Bulk read setup completed")
 
@@ -616,6 +912,20 @@ func (brm *bulkReadManager) setup() (err error) {
 	return
 }
 
+// mapIDs gets the ids of the bulk read maps which have devices.
+func (brm *bulkReadManager) mapIDs() (ids []string) {
+	if len(brm.coilDevices) > 0 {
+		ids = append(ids, "coil")
+	}
+	if len(brm.holdingDevices) > 0 {
+		ids = append(ids, "holding")
+	}
+	if len(brm.inputDevices) > 0 {
+		ids = append(ids, "input")
+	}
+	return
+}
+
 // GetBulkReadMap get the bulk read map and key order for the given mapId.
 // Valid mapIds are coil, holding, input.
 func (brm *bulkReadManager) GetBulkReadMap(mapID string) (
@@ -664,7 +974,12 @@ func (brm *bulkReadManager) GetHoldingShortedOut() (shortedOut bool, err error)
 var brManager bulkReadManager
 
 // AddModbusDevice runs once during plugin initialization for each synse modbus device.
+// Devices with inconsistent device data are rejected here.
 func AddModbusDevice(p *sdk.Plugin, d *sdk.Device) (err error) {
+	if err = ValidateDevice(d); err != nil {
+		log.Errorf("Invalid modbus device: %v", err)
+		return err
+	}
 	return brManager.addModbusDevice(d)
 }
 
@@ -674,6 +989,12 @@ func SetupBulkRead() {
 	brManager.setup()
 }
 
+// endBulkRead ends the bulk read of the map for mapID in the read cycle. The
+// read contexts of computed devices are added when it is the last in the cycle.
+func endBulkRead(mapID string, readContexts []*sdk.ReadContext, err error) ([]*sdk.ReadContext, error) {
+	return brManager.cycle.end(mapID, readContexts, err)
+}
+
 // GetBulkReadMap get the bulk read map and key order for the given mapId.
 // Valid mapIds are coil, holding, input.
 func GetBulkReadMap(mapID string) (
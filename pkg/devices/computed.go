@@ -0,0 +1,255 @@
+package devices
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+	"github.com/vapor-ware/synse-sdk/v2/sdk/output"
+)
+
+// ComputedHandler is a handler for virtual devices whose reading is an
+// expression over the readings of other modbus devices, e.g. total power as
+// the sum of three phases.
+var ComputedHandler = sdk.DeviceHandler{
+	Name:     "computed",
+	BulkRead: bulkReadComputed,
+}
+
+// bulkReadComputed is a noop. Computed readings are made by the last bulk read
+// of modbus devices in each read cycle, see readCycle.
+func bulkReadComputed(devices []*sdk.Device) (readContexts []*sdk.ReadContext, err error) {
+	return nil, nil
+}
+
+// computedDevice is a computed device with its parsed expression.
+type computedDevice struct {
+	device     *sdk.Device
+	expression *utils.Expression
+	sources    map[string]string // Variable name to source device id or info.
+}
+
+// newComputedDevice parses and checks the device data of a computed device.
+func newComputedDevice(device *sdk.Device) (*computedDevice, error) {
+	deviceData, err := config.ComputedDeviceDataFromDevice(device)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode data: %v", err)
+	}
+
+	expression, err := utils.ParseExpression(deviceData.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("'expression': %v", err)
+	}
+	for _, name := range expression.Variables() {
+		if deviceData.Sources[name] == "" {
+			return nil, fmt.Errorf("'sources' has no device for %s", name)
+		}
+	}
+	if len(deviceData.Sources) != len(expression.Variables()) {
+		return nil, fmt.Errorf("'sources' has devices which are not in the 'expression' %q", expression)
+	}
+	return &computedDevice{device: device, expression: expression, sources: deviceData.Sources}, nil
+}
+
+// validateComputedDevice checks the device data of a computed device.
+func validateComputedDevice(device *sdk.Device) error {
+	if output.Get(device.Output) == nil {
+		return fmt.Errorf("device %q: output %q is not registered", device.Info, device.Output)
+	}
+	if _, err := newComputedDevice(device); err != nil {
+		return fmt.Errorf("device %q: %v", device.Info, err)
+	}
+	return nil
+}
+
+// sourceValue is the latest reading of a computed device source. ok is false
+// if the latest reading had no numeric value.
+type sourceValue struct {
+	value float64
+	ok    bool
+}
+
+// sourceValues holds the latest reading of each device by device id and info.
+var sourceValues = make(map[string]sourceValue)
+var sourceMutex sync.Mutex
+
+// ResetSourceValues forgets the latest readings of all devices for test purposes.
+func ResetSourceValues() {
+	sourceMutex.Lock()
+	sourceValues = make(map[string]sourceValue)
+	sourceMutex.Unlock()
+}
+
+// deviceKeys gets the keys a device can be referenced by as a source.
+func deviceKeys(device *sdk.Device) (keys []string) {
+	if id := device.GetID(); id != "" {
+		keys = append(keys, id)
+	}
+	if device.Info != "" {
+		keys = append(keys, device.Info)
+	}
+	return
+}
+
+// checkComputedSources logs an error for each computed device source which
+// does not match the id or info of any device.
+func checkComputedSources(computed []*computedDevice, devices []*sdk.Device) {
+	known := map[string]bool{}
+	for _, device := range devices {
+		for _, key := range deviceKeys(device) {
+			known[key] = true
+		}
+	}
+	for _, c := range computed {
+		for name, source := range c.sources {
+			if !known[source] {
+				log.Errorf("Computed device %q: source %s is %q, which is not a device id or info",
+					c.device.Info, name, source)
+			}
+		}
+	}
+}
+
+// readCycle tracks the bulk reads of the modbus device maps in a read cycle.
+// The SDK runs the bulk reads of each handler in parallel once per cycle, so
+// the computed devices are evaluated once by the bulk read which completes
+// the cycle, from the latest readings of all of their sources.
+type readCycle struct {
+	computed []*computedDevice // The computed devices.
+	maps     []string          // The ids of the bulk read maps with devices.
+	read     map[string]bool   // The maps read in this cycle.
+	updated  map[string]bool   // The source keys updated in this cycle.
+}
+
+// newReadCycle makes a read cycle over the bulk read maps.
+func newReadCycle(computed []*computedDevice, maps []string) *readCycle {
+	return &readCycle{
+		computed: computed,
+		maps:     maps,
+		read:     map[string]bool{},
+		updated:  map[string]bool{},
+	}
+}
+
+// end records the readings from the bulk read of a map as source values. When
+// every map has been read, the read contexts of the computed devices are added
+// to readContexts. A failed bulk read has its readings discarded by the SDK,
+// so the computed devices are then left for the bulk read which completes the
+// next cycle.
+func (c *readCycle) end(mapID string, readContexts []*sdk.ReadContext, err error) ([]*sdk.ReadContext, error) {
+	if c == nil || len(c.computed) == 0 {
+		return readContexts, err
+	}
+
+	sourceMutex.Lock()
+	defer sourceMutex.Unlock()
+
+	if err == nil {
+		for _, readContext := range readContexts {
+			if len(readContext.Reading) > 0 {
+				c.record(readContext.Device, readContext.Reading[0])
+			}
+		}
+	}
+
+	c.read[mapID] = true
+	for _, m := range c.maps {
+		if !c.read[m] {
+			return readContexts, err // Not the last bulk read in this cycle.
+		}
+	}
+	c.read = map[string]bool{}
+	if err != nil {
+		return readContexts, err
+	}
+
+	computedContexts, err := c.compute()
+	if err != nil {
+		return nil, err
+	}
+	return append(readContexts, computedContexts...), nil
+}
+
+// record records a reading as the latest value of its device. The caller holds
+// sourceMutex.
+func (c *readCycle) record(device *sdk.Device, reading *output.Reading) {
+	latest := sourceValue{}
+	latest.value, latest.ok = finalValue(device, reading)
+	for _, key := range deviceKeys(device) {
+		sourceValues[key] = latest
+		c.updated[key] = true
+	}
+}
+
+// compute makes read contexts for the computed devices which have a source
+// updated in this cycle. A computed device may use an earlier computed device
+// in the configuration as a source. The caller holds sourceMutex.
+func (c *readCycle) compute() (computedContexts []*sdk.ReadContext, err error) {
+	for _, computed := range c.computed {
+		var reading *output.Reading
+		reading, err = computed.evaluate(c.updated)
+		if err != nil {
+			return nil, err
+		}
+		if reading == nil {
+			continue // No source in this cycle.
+		}
+		log.Debugf("Appending computed reading: %#v, device: %v", reading, computed.device)
+		c.record(computed.device, reading)
+		computedContexts = append(computedContexts, sdk.NewReadContext(computed.device, []*output.Reading{reading}))
+	}
+	c.updated = map[string]bool{}
+	return
+}
+
+// finalValue gets the numeric value of a reading after the device transforms,
+// which the SDK applies once the bulk read returns. ok is false if the reading
+// has no numeric value.
+func finalValue(device *sdk.Device, reading *output.Reading) (value float64, ok bool) {
+	if reading == nil || reading.Value == nil {
+		return 0, false
+	}
+	final := *reading
+	for _, transformer := range device.Transforms {
+		if err := transformer.Apply(&final); err != nil {
+			return 0, false
+		}
+	}
+	return utils.ToFloat64(final.Value)
+}
+
+// evaluate makes the reading for a computed device. The reading is nil if none
+// of its sources were updated or if a source has never been read. The reading
+// has a nil value if a source has no value or the expression cannot be
+// evaluated (e.g. division by zero). The caller holds sourceMutex.
+func (c *computedDevice) evaluate(updated map[string]bool) (*output.Reading, error) {
+	isUpdated := false
+	vars := map[string]float64{}
+	available := true
+	for name, source := range c.sources {
+		latest, seen := sourceValues[source]
+		if !seen {
+			return nil, nil
+		}
+		isUpdated = isUpdated || updated[source]
+		available = available && latest.ok
+		vars[name] = latest.value
+	}
+	if !isUpdated {
+		return nil, nil
+	}
+
+	theOutput := output.Get(c.device.Output)
+	if !available {
+		return theOutput.MakeReading(nil)
+	}
+	value, err := c.expression.Evaluate(vars)
+	if err != nil {
+		log.Debugf("Computed device %q: %v", c.device.Info, err)
+		return theOutput.MakeReading(nil)
+	}
+	return theOutput.MakeReading(value)
+}
@@ -1,12 +1,23 @@
 package devices
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/goburrow/modbus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
 	modbusOutput "github.com/vapor-ware/synse-modbus-ip-plugin/pkg/outputs"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
 	"github.com/vapor-ware/synse-sdk/v2/sdk"
 	"github.com/vapor-ware/synse-sdk/v2/sdk/funcs"
 	"github.com/vapor-ware/synse-sdk/v2/sdk/output"
@@ -4170,3 +4181,1664 @@ func Test1255(t *testing.T) {
 	assert.Equal(t, 11, len(bulkReadMap[keyOrder[0]]))
 	t.Logf("Test1255 end")
 }
+
+// Device data which is consistent passes validation.
+func TestValidateDevice(t *testing.T) {
+	devices := []*sdk.Device{
+		&sdk.Device{
+			Info: "Test Temperature",
+			Data: map[string]interface{}{
+				"host":    "localhost",
+				"port":    1502,
+				"address": 0x10,
+				"width":   2,
+				"type":    "f32",
+			},
+			Output:  "temperature",
+			Handler: "input_register",
+		},
+		&sdk.Device{
+			Info: "Test Switch",
+			Data: map[string]interface{}{
+				"host":    "localhost",
+				"port":    1502,
+				"address": 0xffff,
+				"type":    "b",
+			},
+			Output:  "switch",
+			Handler: "coil",
+		},
+		&sdk.Device{
+			Info: "Test Serial Number",
+			Data: map[string]interface{}{
+				"host":    "localhost",
+				"port":    1502,
+				"address": 0xfff8,
+				"width":   8,
+				"type":    "t8",
+				"stringOptions": map[string]interface{}{
+					"trim": "all",
+				},
+			},
+			Output:  "string",
+			Handler: "holding_register",
+		},
+		// Write settings are not checked for read only handlers.
+		&sdk.Device{
+			Info: "Test Read Only Register",
+			Data: map[string]interface{}{
+				"host":          "localhost",
+				"port":          1502,
+				"address":       0x20,
+				"width":         1,
+				"type":          "u16",
+				"pulseDuration": "soon",
+				"verifyDelay":   "soon",
+			},
+			Output:  "temperature",
+			Handler: "read_only_holding_register",
+		},
+		// Pulses only apply to coils.
+		&sdk.Device{
+			Info: "Test Register Pulse Duration",
+			Data: map[string]interface{}{
+				"host":          "localhost",
+				"port":          1502,
+				"address":       0x20,
+				"width":         1,
+				"type":          "u16",
+				"pulseDuration": "soon",
+			},
+			Output:  "temperature",
+			Handler: "holding_register",
+		},
+	}
+
+	for _, device := range devices {
+		assert.NoError(t, ValidateDevice(device), device.Info)
+	}
+}
+
+// Inconsistent device data fails validation with an error naming the device
+// info and the field.
+func TestValidateDevice_Error(t *testing.T) {
+	var tests = []struct {
+		field  string
+		data   map[string]interface{}
+		output string
+	}{
+		{
+			field:  "port",
+			data:   map[string]interface{}{"host": "localhost", "address": 1, "width": 1, "type": "u16"},
+			output: "temperature",
+		},
+		{
+			field:  "output",
+			data:   map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16"},
+			output: "nope",
+		},
+		{
+			field:  "width",
+			data:   map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "f32"},
+			output: "temperature",
+		},
+		{
+			field:  "width",
+			data:   map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "type": "t"},
+			output: "temperature",
+		},
+		{
+			field:  "address",
+			data:   map[string]interface{}{"host": "localhost", "port": 1502, "address": 0xffff, "width": 2, "type": "u32"},
+			output: "temperature",
+		},
+		{
+			field:  "type",
+			data:   map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "bytes"},
+			output: "temperature",
+		},
+		{
+			field:  "min",
+			data:   map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16", "min": 10, "max": 1},
+			output: "temperature",
+		},
+		{
+			field:  "rangeAction",
+			data:   map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16", "max": 1, "rangeAction": "wrap"},
+			output: "temperature",
+		},
+		{
+			field: "notAvailable",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"notAvailable": []interface{}{"missing"}},
+			output: "temperature",
+		},
+		{
+			field: "stringOptions",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 2, "type": "u32",
+				"stringOptions": map[string]interface{}{"trim": "all"}},
+			output: "temperature",
+		},
+		{
+			field: "stringOptions",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 2, "type": "t",
+				"stringOptions": map[string]interface{}{"encoding": "ebcdic"}},
+			output: "temperature",
+		},
+		{
+			field: "writeMin",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"writeMin": 10, "writeMax": 1},
+			output: "temperature",
+		},
+		{
+			field: "verifyDelay",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"verifyWrite": true, "verifyDelay": "soon"},
+			output: "temperature",
+		},
+		{
+			field: "rate",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 2, "type": "u32",
+				"rate": map[string]interface{}{"per": "fortnight"}},
+			output: "temperature",
+		},
+		{
+			field: "rate",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 2, "type": "u32",
+				"rate": map[string]interface{}{"output": "nope"}},
+			output: "temperature",
+		},
+		{
+			field: "writePreamble",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"writePreamble": []interface{}{map[string]interface{}{"address": 0x100}}},
+			output: "temperature",
+		},
+		{
+			field: "writePostamble",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"writePostamble": []interface{}{map[string]interface{}{"address": 0x100, "values": []interface{}{0}, "delay": "-1s"}}},
+			output: "temperature",
+		},
+		{
+			field: "coalesceWindow",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"coalesceWindow": "50ms", "writePreamble": []interface{}{map[string]interface{}{"address": 0x100, "values": []interface{}{1}}}},
+			output: "temperature",
+		},
+		{
+			field: "handshake",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"handshake": map[string]interface{}{"statusAddress": 2}},
+			output: "temperature",
+		},
+		{
+			field: "handshake",
+			data: map[string]interface{}{"host": "localhost", "port": 1502, "address": 1, "width": 1, "type": "u16",
+				"handshake": map[string]interface{}{"statusAddress": 2, "done": []interface{}{1}, "error": []interface{}{1}}},
+			output: "temperature",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%d", tt.field, i), func(t *testing.T) {
+			device := &sdk.Device{
+				Info:    "Test Device",
+				Data:    tt.data,
+				Output:  tt.output,
+				Handler: "holding_register",
+			}
+			err := ValidateDevice(device)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "Test Device")
+			assert.Contains(t, err.Error(), tt.field)
+		})
+	}
+}
+
+// AddModbusDevice rejects invalid devices, so they never reach the bulk read manager.
+func TestAddModbusDevice_Invalid(t *testing.T) {
+	device := &sdk.Device{
+		Info: "Test Temperature",
+		Data: map[string]interface{}{
+			"host":    "localhost",
+			"port":    1502,
+			"address": 1,
+			"width":   1,
+			"type":    "f32",
+		},
+		Output:  "temperature",
+		Handler: "holding_register",
+	}
+
+	PurgeBulkReadManager()
+	assert.Error(t, AddModbusDevice(nil, device))
+	assert.Empty(t, brManager.devices)
+}
+
+// Not available values produce a reading with a nil value.
+func TestUnpackReading_NotAvailable(t *testing.T) {
+	theOutput := output.Get("temperature")
+
+	// Device specific values.
+	deviceData := &config.ModbusDeviceData{
+		Type:         "s16",
+		NotAvailable: []interface{}{0x7fff, "-1"},
+	}
+	opts, err := GetReadingOptions(deviceData)
+	assert.NoError(t, err)
+	reading, err := UnpackReadingWithOptions(theOutput, "s16", opts, []byte{0x7f, 0xff}, true)
+	assert.NoError(t, err)
+	assert.Nil(t, reading.Value)
+
+	reading, err = UnpackReadingWithOptions(theOutput, "s16", opts, []byte{0xff, 0xff}, true)
+	assert.NoError(t, err)
+	assert.Nil(t, reading.Value)
+
+	// Type defaults are not used unless enabled.
+	reading, err = UnpackReadingWithOptions(theOutput, "s16", opts, []byte{0x80, 0x00}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int16(-32768), reading.Value)
+
+	deviceData.NotAvailableDefaults = true
+	opts, err = GetReadingOptions(deviceData)
+	assert.NoError(t, err)
+	reading, err = UnpackReadingWithOptions(theOutput, "s16", opts, []byte{0x80, 0x00}, true)
+	assert.NoError(t, err)
+	assert.Nil(t, reading.Value)
+
+	// Real values are unchanged.
+	reading, err = UnpackReadingWithOptions(theOutput, "s16", opts, []byte{0x00, 0x17}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int16(23), reading.Value)
+
+	// UnpackReading has no options, so nothing is not available.
+	reading, err = UnpackReading(theOutput, "s16", []byte{0xff, 0xff}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int16(-1), reading.Value)
+}
+
+// Readings outside of min / max are dropped or clamped and counted.
+func TestApplyRange(t *testing.T) {
+	ResetRangeViolations()
+	theOutput := output.Get("temperature")
+	device := &sdk.Device{Info: "Test Range"}
+	min := -10.5
+	max := 100.5
+
+	var tests = []struct {
+		action   string
+		value    interface{}
+		expected interface{}
+	}{
+		// In range.
+		{action: "", value: float32(20), expected: float32(20)},
+		{action: "clamp", value: int16(-10), expected: int16(-10)},
+		// Dropped.
+		{action: "", value: float32(3e38), expected: nil},
+		{action: "drop", value: int16(-11), expected: nil},
+		{action: "clamp", value: math.NaN(), expected: nil},
+		// Clamped.
+		{action: "clamp", value: float32(3e38), expected: float32(100.5)},
+		{action: "clamp", value: float64(-20), expected: float64(-10.5)},
+		{action: "clamp", value: int16(-11), expected: int16(-10)},
+		{action: "clamp", value: uint32(200), expected: uint32(100)},
+		// Not numeric.
+		{action: "", value: "foo", expected: "foo"},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%d", tt.action, i), func(t *testing.T) {
+			deviceData := &config.ModbusDeviceData{Min: &min, Max: &max, RangeAction: tt.action}
+			reading, err := theOutput.MakeReading(tt.value)
+			assert.NoError(t, err)
+			applyRange(device, deviceData, reading)
+			assert.Equal(t, tt.expected, reading.Value)
+		})
+	}
+	assert.Equal(t, uint64(7), GetRangeViolationCount(device))
+
+	// Limits beyond the type clamp to the limit of the type.
+	over := 70000.0
+	reading, err := theOutput.MakeReading(uint16(12))
+	assert.NoError(t, err)
+	applyRange(device, &config.ModbusDeviceData{Min: &over, RangeAction: "clamp"}, reading)
+	assert.Equal(t, uint16(math.MaxUint16), reading.Value)
+
+	// No limits configured.
+	other := &sdk.Device{Info: "Test No Range"}
+	reading, err = theOutput.MakeReading(float32(3e38))
+	assert.NoError(t, err)
+	applyRange(other, &config.ModbusDeviceData{}, reading)
+	assert.Equal(t, float32(3e38), reading.Value)
+	assert.Equal(t, uint64(0), GetRangeViolationCount(other))
+}
+
+// Test rates derived from successive counter readings, including rollover and reset.
+func TestMakeRateReading(t *testing.T) {
+	ResetCounterSamples()
+	defer func() { now = time.Now }()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	theOutput := output.Get("kilowatt-hour")
+	device := &sdk.Device{Info: "Test Rate"}
+	deviceData := &config.ModbusDeviceData{Type: "u32", Rate: &config.RateOptions{Per: "hour"}}
+
+	var tests = []struct {
+		seconds  int
+		counter  interface{}
+		expected interface{}
+	}{
+		// First sample, no rate yet.
+		{seconds: 0, counter: uint32(math.MaxUint32 - 25), expected: nil},
+		{seconds: 10, counter: uint32(math.MaxUint32 - 15), expected: float64(3600)},
+		// No value, no rate. The previous sample is kept.
+		{seconds: 20, counter: nil, expected: nil},
+		// Rollover.
+		{seconds: 30, counter: uint32(4), expected: float64(3600)},
+		{seconds: 40, counter: uint32(14), expected: float64(3600)},
+		// Reset.
+		{seconds: 50, counter: uint32(0), expected: nil},
+		{seconds: 60, counter: uint32(1), expected: float64(360)},
+	}
+
+	for i, tt := range tests {
+		now = func() time.Time { return start.Add(time.Duration(tt.seconds) * time.Second) }
+		counter, err := theOutput.MakeReading(tt.counter)
+		assert.NoError(t, err)
+
+		reading, err := makeRateReading(device, deviceData, theOutput, counter)
+		assert.NoError(t, err, "case %d", i)
+		assert.Equal(t, tt.expected, reading.Value, "case %d", i)
+		assert.Equal(t, "per hour", reading.Context["rate"], "case %d", i)
+	}
+}
+
+// Test computed devices evaluated once per read cycle from the transformed
+// readings of their sources.
+func TestReadCycle(t *testing.T) {
+	ResetSourceValues()
+	watt := output.Get("watt")
+
+	phases := []*sdk.Device{
+		{Info: "Phase A", Output: "watt"},
+		{Info: "Phase B", Output: "watt", Transforms: []sdk.Transformer{&sdk.ScaleTransformer{Factor: 2}}},
+	}
+	total := &sdk.Device{
+		Info:    "Total Power",
+		Output:  "watt",
+		Handler: "computed",
+		Data: map[string]interface{}{
+			"expression": "a + b",
+			"sources":    map[string]interface{}{"a": "Phase A", "b": "Phase B"},
+		},
+	}
+	half := &sdk.Device{
+		Info:    "Half Power",
+		Output:  "watt",
+		Handler: "computed",
+		Data: map[string]interface{}{
+			"expression": "total / 2",
+			"sources":    map[string]interface{}{"total": "Total Power"},
+		},
+	}
+	var computed []*computedDevice
+	for _, device := range []*sdk.Device{total, half} {
+		assert.NoError(t, ValidateDevice(device))
+		c, err := newComputedDevice(device)
+		assert.NoError(t, err)
+		computed = append(computed, c)
+	}
+	cycle := newReadCycle(computed, []string{"holding", "input"})
+
+	readContexts := func(device *sdk.Device, value interface{}) []*sdk.ReadContext {
+		reading, err := watt.MakeReading(value)
+		assert.NoError(t, err)
+		return []*sdk.ReadContext{sdk.NewReadContext(device, []*output.Reading{reading})}
+	}
+	values := func(contexts []*sdk.ReadContext) (values []interface{}) {
+		for _, c := range contexts {
+			values = append(values, c.Reading[0].Value)
+		}
+		return
+	}
+
+	// Nothing until every map has been read.
+	contexts, err := cycle.end("holding", readContexts(phases[0], float64(100)), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{float64(100)}, values(contexts))
+
+	// Computed devices use the transformed source values, and can use earlier
+	// computed devices. The source readings themselves are not transformed.
+	contexts, err = cycle.end("input", readContexts(phases[1], uint16(50)), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{uint16(50), float64(200), float64(100)}, values(contexts))
+	assert.Equal(t, total, contexts[1].Device)
+	assert.Equal(t, half, contexts[2].Device)
+
+	// No source in this cycle.
+	contexts, err = cycle.end("holding", readContexts(&sdk.Device{Info: "Other"}, float64(1)), nil)
+	assert.NoError(t, err)
+	assert.Len(t, contexts, 1)
+	contexts, err = cycle.end("input", nil, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, contexts)
+
+	// A source with no value gives a nil value. When the last bulk read fails,
+	// the computed devices are made at the end of the next cycle.
+	_, err = cycle.end("holding", readContexts(phases[0], nil), nil)
+	assert.NoError(t, err)
+	_, err = cycle.end("input", nil, fmt.Errorf("timeout"))
+	assert.EqualError(t, err, "timeout")
+	_, err = cycle.end("input", nil, nil)
+	assert.NoError(t, err)
+	contexts, err = cycle.end("holding", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{nil, nil}, values(contexts))
+}
+
+// Computed devices with bad expressions or sources fail validation.
+func TestValidateDevice_Computed_Error(t *testing.T) {
+	var tests = []struct {
+		field string
+		data  map[string]interface{}
+	}{
+		{field: "expression", data: map[string]interface{}{"expression": "a +", "sources": map[string]interface{}{"a": "A"}}},
+		{field: "expression", data: map[string]interface{}{"expression": "a % 2", "sources": map[string]interface{}{"a": "A"}}},
+		{field: "sources", data: map[string]interface{}{"expression": "a + b", "sources": map[string]interface{}{"a": "A"}}},
+		{field: "sources", data: map[string]interface{}{"expression": "a", "sources": map[string]interface{}{"a": "A", "c": "C"}}},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%d", tt.field, i), func(t *testing.T) {
+			device := &sdk.Device{Info: "Test Computed", Output: "watt", Handler: "computed", Data: tt.data}
+			err := ValidateDevice(device)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "Test Computed")
+			assert.Contains(t, err.Error(), tt.field)
+		})
+	}
+}
+
+// Test a device whose value is split across non-contiguous registers. The
+// planner must read every address and the registers are concatenated in the
+// configured order.
+func TestMapBulkRead_Addresses(t *testing.T) {
+	data := func(extra map[string]interface{}) map[string]interface{} {
+		d := map[string]interface{}{"host": "localhost", "port": 1502, "timeout": "1s"}
+		for k, v := range extra {
+			d[k] = v
+		}
+		return d
+	}
+	devices := []*sdk.Device{
+		{
+			Info:    "Contiguous",
+			Data:    data(map[string]interface{}{"address": 0, "width": 4, "type": "u64"}),
+			Output:  "number",
+			Handler: "input_register",
+		},
+		{
+			// Low word inside the contiguous device, high word far away.
+			Info:    "Split",
+			Data:    data(map[string]interface{}{"addresses": []interface{}{500, 2}, "type": "u32"}),
+			Output:  "number",
+			Handler: "input_register",
+		},
+	}
+	for _, device := range devices {
+		assert.NoError(t, ValidateDevice(device))
+	}
+
+	bulkReadMap, keyOrder, err := MapBulkRead(devices, false)
+	assert.NoError(t, err)
+	assert.Len(t, keyOrder, 1)
+	reads := bulkReadMap[keyOrder[0]]
+	assert.Len(t, reads, 2)
+	assert.Equal(t, uint16(0), reads[0].StartRegister)
+	assert.Equal(t, uint16(4), reads[0].RegisterCount) // Not shrunk by register 2.
+	assert.Equal(t, []*sdk.Device{devices[0], devices[1]}, reads[0].Devices)
+	assert.Equal(t, uint16(500), reads[1].StartRegister)
+	assert.Equal(t, uint16(1), reads[1].RegisterCount)
+	assert.Equal(t, []*sdk.Device{devices[1]}, reads[1].Devices)
+
+	reads[0].ReadResults = []byte{0x00, 0x00, 0x00, 0x00, 0x56, 0x78, 0x00, 0x01}
+	reads[1].ReadResults = []byte{0x12, 0x34}
+	readContexts, err := MapBulkReadData(bulkReadMap, keyOrder)
+	assert.NoError(t, err)
+	assert.Len(t, readContexts, 2)
+	assert.Equal(t, devices[0], readContexts[0].Device)
+	assert.Equal(t, uint64(0x56780001), readContexts[0].Reading[0].Value)
+	assert.Equal(t, devices[1], readContexts[1].Device)
+	assert.Equal(t, uint32(0x12345678), readContexts[1].Reading[0].Value)
+
+	// A failed read gives a nil reading.
+	reads[1].ReadResults = []byte{}
+	readContexts, err = MapBulkReadData(bulkReadMap, keyOrder)
+	assert.NoError(t, err)
+	assert.Len(t, readContexts, 2)
+	assert.Nil(t, readContexts[1].Reading[0].Value)
+}
+
+// Devices on the same register each get their reading. Only devices with the
+// same registers and decoding are duplicates.
+func TestMapBulkRead_SameRegister(t *testing.T) {
+	data := func(extra map[string]interface{}) map[string]interface{} {
+		d := map[string]interface{}{"host": "localhost", "port": 1502, "timeout": "1s", "address": 5}
+		for k, v := range extra {
+			d[k] = v
+		}
+		return d
+	}
+	devices := []*sdk.Device{
+		{Info: "Bit 0", Data: data(map[string]interface{}{"width": 1, "type": "b", "bit": 0}), Output: "switch", Handler: "holding_register"},
+		{Info: "Bit 15", Data: data(map[string]interface{}{"width": 1, "type": "b", "bit": 15}), Output: "switch", Handler: "holding_register"},
+		{Info: "Word", Data: data(map[string]interface{}{"width": 1, "type": "u16"}), Output: "number", Handler: "holding_register"},
+		{Info: "Double Word", Data: data(map[string]interface{}{"width": 2, "type": "u32"}), Output: "number", Handler: "holding_register"},
+	}
+	for _, device := range devices {
+		assert.NoError(t, ValidateDevice(device))
+	}
+
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	bulkReadMap, keyOrder, err := MapBulkRead(devices, false)
+	assert.NoError(t, err)
+	for _, entry := range hook.AllEntries() {
+		assert.NotContains(t, entry.Message, "Duplicate")
+	}
+	reads := bulkReadMap[keyOrder[0]]
+	assert.Len(t, reads, 1)
+	assert.Equal(t, devices, reads[0].Devices)
+
+	reads[0].ReadResults = []byte{0x80, 0x01, 0x00, 0x02}
+	readContexts, err := MapBulkReadData(bulkReadMap, keyOrder)
+	assert.NoError(t, err)
+	var values []interface{}
+	for _, readContext := range readContexts {
+		values = append(values, readContext.Reading[0].Value)
+	}
+	assert.Equal(t, []interface{}{true, true, uint16(0x8001), uint32(0x80010002)}, values)
+
+	// The same registers and decoding twice is a duplicate.
+	_, _, err = MapBulkRead(append(devices, devices[2]), false)
+	assert.NoError(t, err)
+	duplicates := 0
+	for _, entry := range hook.AllEntries() {
+		if strings.Contains(entry.Message, "Duplicate modbus device configured") {
+			duplicates++
+		}
+	}
+	assert.Equal(t, 1, duplicates)
+}
+
+func TestValidateDevice_Addresses_Error(t *testing.T) {
+	device := &sdk.Device{
+		Info:    "Test Addresses",
+		Data:    map[string]interface{}{"host": "localhost", "port": 1502, "addresses": []interface{}{1, 9}, "width": 1, "type": "u32"},
+		Output:  "number",
+		Handler: "input_register",
+	}
+	err := ValidateDevice(device)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'addresses'")
+
+	device.Data = map[string]interface{}{"host": "localhost", "port": 1502, "addresses": []interface{}{1}, "type": "u32"}
+	err = ValidateDevice(device)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'width' 1 does not match 'type' u32")
+
+	device.Handler = "coil"
+	err = ValidateDevice(device)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported for coils")
+}
+
+// Test an array device with a reading per value and the index in the context.
+func TestMapBulkReadData_Array(t *testing.T) {
+	devices := []*sdk.Device{
+		{
+			Info: "Phase Currents",
+			Data: map[string]interface{}{
+				"host": "localhost", "port": 1502, "timeout": "1s",
+				"address": 10, "width": 1, "type": "u16", "count": 3, "stride": 2,
+			},
+			Output:  "electric-current",
+			Handler: "input_register",
+		},
+		{
+			Info: "After",
+			Data: map[string]interface{}{
+				"host": "localhost", "port": 1502, "timeout": "1s",
+				"address": 15, "width": 1, "type": "u16",
+			},
+			Output:  "number",
+			Handler: "input_register",
+		},
+	}
+	for _, device := range devices {
+		assert.NoError(t, ValidateDevice(device))
+	}
+
+	bulkReadMap, keyOrder, err := MapBulkRead(devices, false)
+	assert.NoError(t, err)
+	reads := bulkReadMap[keyOrder[0]]
+	assert.Len(t, reads, 1)
+	assert.Equal(t, uint16(10), reads[0].StartRegister)
+	assert.Equal(t, uint16(6), reads[0].RegisterCount)
+
+	reads[0].ReadResults = []byte{0x00, 0x01, 0xff, 0xff, 0x00, 0x02, 0xff, 0xff, 0x00, 0x03, 0x00, 0x04}
+	readContexts, err := MapBulkReadData(bulkReadMap, keyOrder)
+	assert.NoError(t, err)
+	assert.Len(t, readContexts, 2)
+	assert.Len(t, readContexts[0].Reading, 3)
+	for i, reading := range readContexts[0].Reading {
+		assert.Equal(t, uint16(i+1), reading.Value)
+		assert.Equal(t, map[string]string{"index": fmt.Sprint(i)}, reading.Context)
+	}
+	assert.Equal(t, uint16(4), readContexts[1].Reading[0].Value)
+
+	// A short read gives a nil reading for each value.
+	reads[0].ReadResults = []byte{0x00, 0x01}
+	readContexts, err = MapBulkReadData(bulkReadMap, keyOrder)
+	assert.NoError(t, err)
+	assert.Len(t, readContexts[0].Reading, 3)
+	for _, reading := range readContexts[0].Reading {
+		assert.Nil(t, reading.Value)
+	}
+}
+
+func TestValidateDevice_Array_Error(t *testing.T) {
+	var tests = []struct {
+		field string
+		data  map[string]interface{}
+	}{
+		{field: "stride", data: map[string]interface{}{"width": 2, "type": "u32", "count": 2, "stride": 1}},
+		{field: "count", data: map[string]interface{}{"width": 2, "type": "u32", "count": 62}},
+		{field: "count", data: map[string]interface{}{"address": 0xfff0, "width": 2, "type": "u32", "count": 10}},
+		{field: "rate", data: map[string]interface{}{"width": 2, "type": "u32", "count": 2, "rate": map[string]interface{}{}}},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%d", tt.field, i), func(t *testing.T) {
+			tt.data["host"] = "localhost"
+			tt.data["port"] = 1502
+			device := &sdk.Device{Info: "Test Array", Data: tt.data, Output: "number", Handler: "input_register"}
+			err := ValidateDevice(device)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "Test Array")
+			assert.Contains(t, err.Error(), tt.field)
+		})
+	}
+}
+
+// fakeClient is an in-memory modbus.Client for testing writes. It records the
+// name of each call made and fails calls named in errors.
+type fakeClient struct {
+	registers map[uint16]uint16
+	coils     map[uint16]bool
+	calls     []string
+	errors    map[string]error
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		registers: make(map[uint16]uint16),
+		coils:     make(map[uint16]bool),
+		errors:    make(map[string]error),
+	}
+}
+
+func (c *fakeClient) call(name string) error {
+	c.calls = append(c.calls, name)
+	return c.errors[name]
+}
+
+func (c *fakeClient) ReadCoils(address, quantity uint16) ([]byte, error) {
+	if err := c.call("ReadCoils"); err != nil {
+		return nil, err
+	}
+	results := make([]byte, (quantity+7)/8)
+	for i := uint16(0); i < quantity; i++ {
+		if c.coils[address+i] {
+			results[i/8] |= 1 << (i % 8)
+		}
+	}
+	return results, nil
+}
+
+func (c *fakeClient) ReadDiscreteInputs(address, quantity uint16) ([]byte, error) {
+	return nil, c.call("ReadDiscreteInputs")
+}
+
+func (c *fakeClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	if err := c.call("WriteSingleCoil"); err != nil {
+		return nil, err
+	}
+	c.coils[address] = value == 0xff00
+	return []byte{byte(value >> 8), byte(value)}, nil
+}
+
+func (c *fakeClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	if err := c.call("WriteMultipleCoils"); err != nil {
+		return nil, err
+	}
+	for i := uint16(0); i < quantity; i++ {
+		c.coils[address+i] = value[i/8]&(1<<(i%8)) != 0
+	}
+	return []byte{byte(quantity >> 8), byte(quantity)}, nil
+}
+
+func (c *fakeClient) ReadInputRegisters(address, quantity uint16) ([]byte, error) {
+	return nil, c.call("ReadInputRegisters")
+}
+
+func (c *fakeClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	if err := c.call("ReadHoldingRegisters"); err != nil {
+		return nil, err
+	}
+	results := make([]byte, 2*quantity)
+	for i := uint16(0); i < quantity; i++ {
+		results[2*i] = byte(c.registers[address+i] >> 8)
+		results[2*i+1] = byte(c.registers[address+i])
+	}
+	return results, nil
+}
+
+func (c *fakeClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	if err := c.call("WriteSingleRegister"); err != nil {
+		return nil, err
+	}
+	c.registers[address] = value
+	return []byte{byte(value >> 8), byte(value)}, nil
+}
+
+func (c *fakeClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	if err := c.call("WriteMultipleRegisters"); err != nil {
+		return nil, err
+	}
+	for i := uint16(0); i < quantity; i++ {
+		c.registers[address+i] = uint16(value[2*i])<<8 | uint16(value[2*i+1])
+	}
+	return []byte{byte(quantity >> 8), byte(quantity)}, nil
+}
+
+func (c *fakeClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
+	return nil, c.call("ReadWriteMultipleRegisters")
+}
+
+func (c *fakeClient) MaskWriteRegister(address, andMask, orMask uint16) ([]byte, error) {
+	if err := c.call("MaskWriteRegister"); err != nil {
+		return nil, err
+	}
+	c.registers[address] = (c.registers[address] & andMask) | (orMask &^ andMask)
+	return []byte{byte(andMask >> 8), byte(andMask), byte(orMask >> 8), byte(orMask)}, nil
+}
+
+func (c *fakeClient) ReadFIFOQueue(address uint16) ([]byte, error) {
+	return nil, c.call("ReadFIFOQueue")
+}
+
+// Test holding register writes, both the legacy hex write and typed writes.
+func TestWriteHoldingRegisterData(t *testing.T) {
+	var tests = []struct {
+		name      string
+		data      config.ModbusDeviceData
+		value     string
+		call      string
+		registers map[uint16]uint16
+	}{
+		{
+			name:      "hex",
+			data:      config.ModbusDeviceData{Address: 10, Width: 1, Type: "u16"},
+			value:     "1f",
+			call:      "WriteSingleRegister",
+			registers: map[uint16]uint16{10: 0x1f},
+		},
+		{
+			name:      "u16",
+			data:      config.ModbusDeviceData{Address: 10, Width: 1, Type: "u16", TypedWrite: true},
+			value:     "31",
+			call:      "WriteMultipleRegisters",
+			registers: map[uint16]uint16{10: 31},
+		},
+		{
+			name:      "s32",
+			data:      config.ModbusDeviceData{Address: 10, Width: 2, Type: "s32", TypedWrite: true},
+			value:     "-2",
+			call:      "WriteMultipleRegisters",
+			registers: map[uint16]uint16{10: 0xffff, 11: 0xfffe},
+		},
+		{
+			name:      "f32",
+			data:      config.ModbusDeviceData{Address: 10, Width: 2, Type: "f32", TypedWrite: true},
+			value:     "21.5",
+			call:      "WriteMultipleRegisters",
+			registers: map[uint16]uint16{10: 0x41ac, 11: 0x0000},
+		},
+		{
+			name:      "string",
+			data:      config.ModbusDeviceData{Address: 10, Width: 2, Type: "t", TypedWrite: true},
+			value:     "abc",
+			call:      "WriteMultipleRegisters",
+			registers: map[uint16]uint16{10: 0x6162, 11: 0x6300},
+		},
+		{
+			name: "string options",
+			data: config.ModbusDeviceData{Address: 10, Width: 2, Type: "t", TypedWrite: true,
+				StringOptions: &config.StringOptions{ByteSwap: true, Encoding: "latin1"}},
+			value:     "abé",
+			call:      "WriteMultipleRegisters",
+			registers: map[uint16]uint16{10: 0x6261, 11: 0x00e9},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			err := writeHoldingRegisterData(client, &tt.data, &sdk.WriteData{Data: []byte(tt.value)})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tt.call}, client.calls)
+			assert.Equal(t, tt.registers, client.registers)
+		})
+	}
+}
+
+func TestWriteHoldingRegisterData_Error(t *testing.T) {
+	var tests = []struct {
+		data  config.ModbusDeviceData
+		value string
+	}{
+		{data: config.ModbusDeviceData{Width: 1, Type: "u16"}, value: "fffff"},
+		{data: config.ModbusDeviceData{Width: 1, Type: "u16", TypedWrite: true}, value: "65536"},
+		{data: config.ModbusDeviceData{Width: 1, Type: "s16", TypedWrite: true}, value: "1.5"},
+		{data: config.ModbusDeviceData{Width: 2, Type: "t", TypedWrite: true}, value: "too long"},
+		{data: config.ModbusDeviceData{Width: 3, Type: "macaddress", TypedWrite: true}, value: "00:00:00:00:00:00"},
+	}
+
+	for _, tt := range tests {
+		client := newFakeClient()
+		err := writeHoldingRegisterData(client, &tt.data, &sdk.WriteData{Data: []byte(tt.value)})
+		assert.Error(t, err, tt.value)
+		assert.Empty(t, client.calls, "nothing is sent for %s", tt.value)
+	}
+}
+
+// Test write verification by reading the written value back.
+func TestWrite_Verify(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	deviceData := &config.ModbusDeviceData{Address: 10, Width: 2, Type: "f32", TypedWrite: true, VerifyWrite: true, VerifyDelay: "50ms"}
+	client := newFakeClient()
+	err := writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte("21.5")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"WriteMultipleRegisters", "ReadHoldingRegisters"}, client.calls)
+	assert.Equal(t, []time.Duration{50 * time.Millisecond}, slept)
+
+	// The device does not take the value.
+	deviceData = &config.ModbusDeviceData{Address: 10, Width: 1, Type: "u16", VerifyWrite: true}
+	err = writeHoldingRegisterData(&ignoringClient{newFakeClient()}, deviceData, &sdk.WriteData{Data: []byte("1f")})
+	assert.EqualError(t, err, "write verification failed: wrote 0x001f to register 0xa, read back 0x0000")
+
+	// The read back fails.
+	client = newFakeClient()
+	client.errors["ReadHoldingRegisters"] = fmt.Errorf("timeout")
+	err = writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte("1f")})
+	assert.EqualError(t, err, "write verification failed: read back of register 0xa: timeout")
+
+	// Coils.
+	deviceData = &config.ModbusDeviceData{Address: 3, VerifyWrite: true}
+	client = newFakeClient()
+	err = writeCoilData(client, deviceData, &sdk.WriteData{Data: []byte("true")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"WriteSingleCoil", "ReadCoils"}, client.calls)
+
+	err = writeCoilData(&ignoringClient{newFakeClient()}, deviceData, &sdk.WriteData{Data: []byte("1")})
+	assert.EqualError(t, err, "write verification failed: wrote true to coil 0x3, read back false")
+
+	// No verification.
+	client = newFakeClient()
+	err = writeCoilData(client, &config.ModbusDeviceData{Address: 3}, &sdk.WriteData{Data: []byte("1")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"WriteSingleCoil"}, client.calls)
+}
+
+// ignoringClient echoes writes without changing the device, like a device
+// which ignores writes to a register.
+type ignoringClient struct {
+	*fakeClient
+}
+
+func (c *ignoringClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	return []byte{byte(value >> 8), byte(value)}, c.call("WriteSingleRegister")
+}
+
+func (c *ignoringClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	return []byte{byte(value >> 8), byte(value)}, c.call("WriteSingleCoil")
+}
+
+// Test bit writes with mask write register and the read-modify-write fallback.
+func TestWriteHoldingRegisterData_Bit(t *testing.T) {
+	bit := uint16(3)
+	deviceData := &config.ModbusDeviceData{Address: 10, Width: 1, Type: "b", Bit: &bit, VerifyWrite: true}
+
+	var tests = []struct {
+		name     string
+		value    string
+		before   uint16
+		after    uint16
+		fallback bool
+	}{
+		{name: "set", value: "1", before: 0xf0f0, after: 0xf0f8},
+		{name: "clear", value: "false", before: 0xffff, after: 0xfff7},
+		{name: "set fallback", value: "true", before: 0x0001, after: 0x0009, fallback: true},
+		{name: "clear fallback", value: "0", before: 0x0009, after: 0x0001, fallback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			client.registers[10] = tt.before
+			calls := []string{"MaskWriteRegister", "ReadHoldingRegisters"}
+			if tt.fallback {
+				client.errors["MaskWriteRegister"] = &modbus.ModbusError{
+					FunctionCode: 0x96, ExceptionCode: modbus.ExceptionCodeIllegalFunction}
+				calls = []string{"MaskWriteRegister", "ReadHoldingRegisters", "WriteSingleRegister", "ReadHoldingRegisters"}
+			}
+
+			err := writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte(tt.value)})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.after, client.registers[10])
+			assert.Equal(t, calls, client.calls)
+		})
+	}
+
+	// Other errors are not retried.
+	client := newFakeClient()
+	client.errors["MaskWriteRegister"] = &modbus.ModbusError{
+		FunctionCode: 0x96, ExceptionCode: modbus.ExceptionCodeIllegalDataAddress}
+	err := writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte("1")})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"MaskWriteRegister"}, client.calls)
+
+	err = writeHoldingRegisterData(newFakeClient(), deviceData, &sdk.WriteData{Data: []byte("2")})
+	assert.EqualError(t, err, "unknown bit data 2")
+}
+
+// Bit devices read their bit as a boolean.
+func TestUnpackReading_Bit(t *testing.T) {
+	theOutput := output.Get("switch")
+	for bit, expected := range []bool{true, false, false, true} {
+		b := uint16(bit) + 12
+		reading, err := UnpackReadingWithOptions(theOutput, "b", ReadingOptions{Bit: &b}, []byte{0x90, 0x00}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, reading.Value, "bit %d", b)
+	}
+}
+
+func TestValidateDevice_Bit_Error(t *testing.T) {
+	var tests = []struct {
+		handler string
+		data    map[string]interface{}
+		message string
+	}{
+		{handler: "holding_register", data: map[string]interface{}{"width": 1, "bit": 16}, message: "'bit' 16 must be 0 to 15"},
+		{handler: "holding_register", data: map[string]interface{}{"width": 2, "bit": 1}, message: "'width' must be 1"},
+		{handler: "coil", data: map[string]interface{}{"bit": 1}, message: "not supported for the coil handler"},
+		{handler: "holding_register", data: map[string]interface{}{"width": 1, "bit": 1, "typedWrite": true}, message: "'typedWrite'"},
+	}
+
+	for _, tt := range tests {
+		tt.data["host"] = "localhost"
+		tt.data["port"] = 1502
+		tt.data["type"] = "b"
+		device := &sdk.Device{Info: "Test Bit", Data: tt.data, Output: "switch", Handler: tt.handler}
+		err := ValidateDevice(device)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), tt.message)
+	}
+}
+
+// Test the toggle and pulse coil write actions.
+func TestWriteCoilData_Actions(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+	deviceData := &config.ModbusDeviceData{Address: 3, PulseDuration: "250ms"}
+
+	// Toggle.
+	client := newFakeClient()
+	assert.NoError(t, writeCoilData(client, deviceData, &sdk.WriteData{Action: "toggle"}))
+	assert.True(t, client.coils[3])
+	assert.NoError(t, writeCoilData(client, deviceData, &sdk.WriteData{Action: "toggle"}))
+	assert.False(t, client.coils[3])
+	assert.Equal(t, []string{"ReadCoils", "WriteSingleCoil", "ReadCoils", "WriteSingleCoil"}, client.calls)
+
+	// Pulse for the configured duration, then for the duration in the data.
+	client = newFakeClient()
+	assert.NoError(t, writeCoilData(client, deviceData, &sdk.WriteData{Action: "pulse"}))
+	assert.NoError(t, writeCoilData(client, deviceData, &sdk.WriteData{Action: "pulse", Data: []byte("2s")}))
+	assert.False(t, client.coils[3])
+	assert.Equal(t, []string{"WriteSingleCoil", "WriteSingleCoil", "WriteSingleCoil", "WriteSingleCoil"}, client.calls)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 2 * time.Second}, slept)
+
+	// The off write is retried.
+	client = newFakeClient()
+	offFails := &failingOffClient{fakeClient: client, failures: 2}
+	assert.NoError(t, writeCoilData(offFails, deviceData, &sdk.WriteData{Action: "pulse"}))
+	assert.False(t, client.coils[3])
+	assert.Equal(t, 4, len(client.calls))
+
+	offFails = &failingOffClient{fakeClient: newFakeClient(), failures: pulseOffAttempts}
+	err := writeCoilData(offFails, deviceData, &sdk.WriteData{Action: "pulse"})
+	assert.EqualError(t, err, "coil 0x3 may still be on, failed to end pulse: timeout")
+
+	// A failed on write may have turned the coil on, so the coil is turned off
+	// right away, unless the device rejected the on write with an exception.
+	slept = nil
+	onFails := &failingOnClient{fakeClient: newFakeClient(), err: fmt.Errorf("timeout")}
+	err = writeCoilData(onFails, deviceData, &sdk.WriteData{Action: "pulse"})
+	assert.EqualError(t, err, "timeout")
+	assert.Equal(t, []string{"WriteSingleCoil", "WriteSingleCoil"}, onFails.calls)
+	assert.Empty(t, slept)
+
+	exception := &modbus.ModbusError{FunctionCode: modbus.FuncCodeWriteSingleCoil, ExceptionCode: modbus.ExceptionCodeIllegalDataAddress}
+	onFails = &failingOnClient{fakeClient: newFakeClient(), err: exception}
+	err = writeCoilData(onFails, deviceData, &sdk.WriteData{Action: "pulse"})
+	assert.Equal(t, exception, err)
+	assert.Equal(t, []string{"WriteSingleCoil"}, onFails.calls)
+
+	// Bad durations do not write.
+	client = newFakeClient()
+	assert.Error(t, writeCoilData(client, deviceData, &sdk.WriteData{Action: "pulse", Data: []byte("soon")}))
+	assert.Error(t, writeCoilData(client, deviceData, &sdk.WriteData{Action: "pulse", Data: []byte("-1s")}))
+	err = writeCoilData(client, deviceData, &sdk.WriteData{Action: "pulse", Data: []byte("2m")})
+	assert.EqualError(t, err, "pulse duration 2m0s is longer than 1m0s")
+	assert.Empty(t, client.calls)
+
+	// A bad configured duration is rejected at load for coils.
+	coil := &sdk.Device{
+		Info:    "Test Pulse Coil",
+		Data:    map[string]interface{}{"host": "localhost", "port": 1502, "address": 3, "pulseDuration": "soon"},
+		Output:  "switch",
+		Handler: "coil",
+	}
+	assert.EqualError(t, ValidateDevice(coil), `device "Test Pulse Coil": 'pulseDuration' "soon" must be a positive duration`)
+}
+
+// failingOnClient fails coil on writes with err.
+type failingOnClient struct {
+	*fakeClient
+	err error
+}
+
+func (c *failingOnClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	if value == 0xFF00 {
+		c.call("WriteSingleCoil")
+		return nil, c.err
+	}
+	return c.fakeClient.WriteSingleCoil(address, value)
+}
+
+// failingOffClient fails the first failures coil off writes.
+type failingOffClient struct {
+	*fakeClient
+	failures int
+}
+
+func (c *failingOffClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	if value == 0 && c.failures > 0 {
+		c.failures--
+		c.call("WriteSingleCoil")
+		return nil, fmt.Errorf("timeout")
+	}
+	return c.fakeClient.WriteSingleCoil(address, value)
+}
+
+// Writes outside of the write limits are rejected before anything is sent.
+func TestWrite_Limits(t *testing.T) {
+	min, max := 10.0, 22.5
+	notWritable := false
+	bit := uint16(0)
+
+	var tests = []struct {
+		name    string
+		data    config.ModbusDeviceData
+		coil    bool
+		write   sdk.WriteData
+		message string
+	}{
+		{
+			name:    "hex above max",
+			data:    config.ModbusDeviceData{Type: "u16", Width: 1, WriteMax: &max},
+			write:   sdk.WriteData{Data: []byte("7fff")},
+			message: "write rejected: value 32767 is above writeMax 22.5",
+		},
+		{
+			name:    "typed below min",
+			data:    config.ModbusDeviceData{Type: "s16", Width: 1, TypedWrite: true, WriteMin: &min},
+			write:   sdk.WriteData{Data: []byte("-5")},
+			message: "write rejected: value -5 is below writeMin 10",
+		},
+		{
+			name:    "float at max",
+			data:    config.ModbusDeviceData{Type: "f32", Width: 2, TypedWrite: true, WriteMin: &min, WriteMax: &max},
+			write:   sdk.WriteData{Data: []byte("22.5")},
+			message: "",
+		},
+		{
+			name:    "float NaN",
+			data:    config.ModbusDeviceData{Type: "f32", Width: 2, TypedWrite: true, WriteMax: &max},
+			write:   sdk.WriteData{Data: []byte("NaN")},
+			message: "write rejected: value NaN is outside of the write limits",
+		},
+		{
+			name:    "not allowed",
+			data:    config.ModbusDeviceData{Type: "u16", Width: 1, TypedWrite: true, WriteAllowed: []float64{0, 1, 2}},
+			write:   sdk.WriteData{Data: []byte("3")},
+			message: "write rejected: value 3 is not one of writeAllowed [0 1 2]",
+		},
+		{
+			name:    "allowed",
+			data:    config.ModbusDeviceData{Type: "u16", Width: 1, TypedWrite: true, WriteAllowed: []float64{0, 1, 2}},
+			write:   sdk.WriteData{Data: []byte("2")},
+			message: "",
+		},
+		{
+			name:    "not writable",
+			data:    config.ModbusDeviceData{Type: "u16", Width: 1, Writable: &notWritable},
+			write:   sdk.WriteData{Data: []byte("1")},
+			message: "write rejected: device is not writable",
+		},
+		{
+			name:    "bit not allowed",
+			data:    config.ModbusDeviceData{Type: "b", Width: 1, Bit: &bit, WriteAllowed: []float64{0}},
+			write:   sdk.WriteData{Data: []byte("1")},
+			message: "write rejected: value 1 is not one of writeAllowed [0]",
+		},
+		{
+			name:    "coil not writable",
+			data:    config.ModbusDeviceData{Writable: &notWritable},
+			coil:    true,
+			write:   sdk.WriteData{Action: "toggle"},
+			message: "write rejected: device is not writable",
+		},
+		{
+			name:    "coil pulse not allowed",
+			data:    config.ModbusDeviceData{WriteAllowed: []float64{0}},
+			coil:    true,
+			write:   sdk.WriteData{Action: "pulse"},
+			message: "write rejected: value 1 is not one of writeAllowed [0]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient()
+			var err error
+			if tt.coil {
+				err = writeCoilData(client, &tt.data, &tt.write)
+			} else {
+				err = writeHoldingRegisterData(client, &tt.data, &tt.write)
+			}
+			if tt.message == "" {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, client.calls)
+				return
+			}
+			assert.EqualError(t, err, tt.message)
+			assert.Empty(t, client.calls)
+		})
+	}
+}
+
+// Writes made through the audit client are appended to the audit file.
+func TestWriteAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	assert.NoError(t, SetWriteAuditFile(path, 1024, 2))
+	defer SetWriteAuditFile("", 0, 0)
+
+	deviceData := &config.ModbusDeviceData{Host: "10.1.2.3", Port: 502, SlaveID: 7, Address: 3, Type: "u16", Width: 1}
+	device := &sdk.Device{Info: "setpoint"}
+	fake := newFakeClient()
+	fake.coils[3] = true
+	fake.errors["WriteSingleRegister"] = fmt.Errorf("timeout")
+
+	assert.NoError(t, writeCoilData(newAuditClient(fake, device, deviceData), deviceData, &sdk.WriteData{Action: "toggle"}))
+	assert.EqualError(t, writeHoldingRegisterData(newAuditClient(fake, device, deviceData), deviceData, &sdk.WriteData{Data: []byte("1f")}), "timeout")
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	var entries []WriteAuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry WriteAuditEntry
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, 2, len(entries))
+
+	assert.Equal(t, "setpoint", entries[0].Info)
+	assert.Equal(t, "10.1.2.3", entries[0].Host)
+	assert.Equal(t, 7, entries[0].Unit)
+	assert.Equal(t, uint16(3), entries[0].Address)
+	assert.Equal(t, byte(modbus.FuncCodeWriteSingleCoil), entries[0].FunctionCode)
+	assert.Equal(t, "0000", entries[0].Payload)
+	assert.Equal(t, "ff00", entries[0].Previous)
+	assert.Equal(t, "ok", entries[0].Result)
+
+	assert.Equal(t, byte(modbus.FuncCodeWriteSingleRegister), entries[1].FunctionCode)
+	assert.Equal(t, "001f", entries[1].Payload)
+	assert.Equal(t, "", entries[1].Previous)
+	assert.Equal(t, "timeout", entries[1].Result)
+
+	// The file is rotated at the maximum size, keeping two rotated files.
+	client := newAuditClient(newFakeClient(), device, deviceData)
+	for i := 0; i < 30; i++ {
+		_, err = client.WriteSingleRegister(uint16(i), uint16(i))
+		assert.NoError(t, err)
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(1024))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
+
+// Writes to contiguous registers within the coalescing window are sent in one
+// request, and each write gets the result of its request.
+func TestCoalesce(t *testing.T) {
+	fake := newFakeClient()
+	newClient = func(deviceData *config.ModbusDeviceData) (modbus.Client, *modbus.TCPClientHandler, error) {
+		return fake, modbus.NewTCPClientHandler("localhost:502"), nil
+	}
+	defer func() { newClient = utils.NewClient }()
+
+	write := func(address uint16, value string, typed bool) chan error {
+		done := make(chan error, 1)
+		deviceData := &config.ModbusDeviceData{Host: "10.1.2.3", Port: 502, Address: address,
+			Type: "u32", Width: 2, TypedWrite: typed, CoalesceWindow: "50ms"}
+		if !typed {
+			deviceData.Type, deviceData.Width = "u16", 1
+		}
+		go func() { done <- coalesceRegisterWrite(nil, deviceData, &sdk.WriteData{Data: []byte(value)}) }()
+		return done
+	}
+	results := []chan error{write(10, "1", false), write(11, "70000", true), write(13, "ff", false), write(20, "2", false)}
+	for _, done := range results {
+		assert.NoError(t, <-done)
+	}
+
+	assert.Equal(t, []string{"WriteMultipleRegisters", "WriteSingleRegister"}, fake.calls)
+	assert.Equal(t, map[uint16]uint16{10: 1, 11: 0x1, 12: 0x1170, 13: 0xff, 20: 2}, fake.registers)
+
+	// Writes with a different window or timeout are not held together.
+	var mutex sync.Mutex
+	var clients []*fakeClient
+	var timeouts []string
+	newClient = func(deviceData *config.ModbusDeviceData) (modbus.Client, *modbus.TCPClientHandler, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		client := newFakeClient()
+		clients = append(clients, client)
+		timeouts = append(timeouts, deviceData.Timeout)
+		return client, modbus.NewTCPClientHandler("localhost:502"), nil
+	}
+	writeWith := func(address uint16, window, timeout string) chan error {
+		done := make(chan error, 1)
+		deviceData := &config.ModbusDeviceData{Host: "10.1.2.3", Port: 502, Address: address,
+			Type: "u16", Width: 1, Timeout: timeout, CoalesceWindow: window}
+		go func() { done <- coalesceRegisterWrite(nil, deviceData, &sdk.WriteData{Data: []byte("1")}) }()
+		return done
+	}
+	results = []chan error{writeWith(10, "50ms", "1s"), writeWith(11, "20ms", "1s"), writeWith(12, "50ms", "5s")}
+	for _, done := range results {
+		assert.NoError(t, <-done)
+	}
+	assert.Len(t, clients, 3)
+	for _, client := range clients {
+		assert.Equal(t, []string{"WriteSingleRegister"}, client.calls)
+	}
+	assert.ElementsMatch(t, []string{"1s", "1s", "5s"}, timeouts)
+
+	// Overlapping writes and coils.
+	fake = newFakeClient()
+	fake.errors["WriteSingleCoil"] = fmt.Errorf("timeout")
+	batch := []*pendingWrite{
+		{coil: true, address: 5, quantity: 1, payload: []byte{0xff, 0}},
+		{coil: true, address: 4, quantity: 1, payload: []byte{0xff, 0}},
+		{coil: true, address: 5, quantity: 1, payload: []byte{0, 0}},
+	}
+	for _, w := range batch {
+		w.deviceData = &config.ModbusDeviceData{}
+		w.done = make(chan error, 1)
+	}
+	sendCoalesced(fake, batch)
+	assert.NoError(t, <-batch[0].done)
+	assert.NoError(t, <-batch[1].done)
+	assert.EqualError(t, <-batch[2].done, "timeout")
+	assert.Equal(t, []string{"WriteMultipleCoils", "WriteSingleCoil"}, fake.calls)
+	assert.Equal(t, map[uint16]bool{4: true, 5: true}, fake.coils)
+
+	// A write which partly overlaps an earlier write is made after it, even at a
+	// lower address.
+	fake = newFakeClient()
+	batch = []*pendingWrite{
+		{address: 11, quantity: 1, payload: []byte{0x00, 0x01}},
+		{address: 10, quantity: 2, payload: []byte{0x00, 0x02, 0x00, 0x03}},
+		{address: 12, quantity: 1, payload: []byte{0x00, 0x04}},
+	}
+	for _, w := range batch {
+		w.deviceData = &config.ModbusDeviceData{}
+		w.done = make(chan error, 1)
+	}
+	sendCoalesced(fake, batch)
+	for _, w := range batch {
+		assert.NoError(t, <-w.done)
+	}
+	assert.Equal(t, []string{"WriteSingleRegister", "WriteMultipleRegisters"}, fake.calls)
+	assert.Equal(t, map[uint16]uint16{10: 2, 11: 3, 12: 4}, fake.registers)
+}
+
+// Concurrent writes through the write handlers are coalesced, and each written
+// device is read back once on the connection of the batch. Writes made one at
+// a time, as the SDK makes them in serial mode, are each sent alone.
+func TestCoalesce_WriteHandler(t *testing.T) {
+	var mutex sync.Mutex
+	var clients []*fakeClient
+	newClient = func(deviceData *config.ModbusDeviceData) (modbus.Client, *modbus.TCPClientHandler, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		client := newFakeClient()
+		clients = append(clients, client)
+		return client, modbus.NewTCPClientHandler("localhost:502"), nil
+	}
+	defer func() { newClient = utils.NewClient }()
+	used := func() (calls [][]string) {
+		for _, client := range clients {
+			if len(client.calls) > 0 {
+				calls = append(calls, client.calls)
+			}
+		}
+		return
+	}
+
+	makeDevice := func(address int) *sdk.Device {
+		return &sdk.Device{
+			Info: fmt.Sprintf("setpoint %d", address),
+			Data: map[string]interface{}{
+				"host": "10.1.2.3", "port": 502, "timeout": "1s",
+				"address": address, "width": 1, "type": "u16", "coalesceWindow": "50ms",
+			},
+			Output:  "number",
+			Handler: "holding_register",
+		}
+	}
+	devices := []*sdk.Device{makeDevice(10), makeDevice(11)}
+	readings := make(chan *sdk.ReadContext, 4)
+	assert.NoError(t, listenForWrites(devices[0], readings))
+	defer func() { publishReadings = nil }()
+
+	var wg sync.WaitGroup
+	for _, device := range devices {
+		wg.Add(1)
+		go func(device *sdk.Device) {
+			defer wg.Done()
+			assert.NoError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("1")}))
+		}(device)
+	}
+	wg.Wait()
+	assert.Equal(t, [][]string{{"WriteMultipleRegisters", "ReadHoldingRegisters", "ReadHoldingRegisters"}}, used())
+	assert.Len(t, readings, 2)
+	for range devices {
+		readContext := <-readings
+		assert.Equal(t, uint16(1), readContext.Reading[0].Value)
+	}
+
+	clients = nil
+	for _, device := range devices {
+		assert.NoError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("2")}))
+	}
+	assert.Equal(t, [][]string{
+		{"WriteSingleRegister", "ReadHoldingRegisters"},
+		{"WriteSingleRegister", "ReadHoldingRegisters"},
+	}, used())
+}
+
+// Dry run writes are validated and encoded but never sent.
+func TestWrite_DryRun(t *testing.T) {
+	device := &sdk.Device{
+		Info: "setpoint",
+		Data: map[string]interface{}{
+			"host":       "192.0.2.1",
+			"port":       502,
+			"timeout":    "100ms",
+			"address":    10,
+			"type":       "s16",
+			"width":      1,
+			"typedWrite": true,
+			"writeMax":   100,
+			"dryRun":     true,
+		},
+	}
+	assert.NoError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("-12")}))
+	assert.EqualError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("500")}),
+		"write rejected: value 500 is above writeMax 100")
+	assert.EqualError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("twelve")}),
+		`invalid 16-bit signed integer "twelve"`)
+
+	// Plugin level dry run.
+	coil := &sdk.Device{
+		Info: "relay",
+		Data: map[string]interface{}{"host": "192.0.2.1", "port": 502, "timeout": "100ms", "address": 3, "verifyWrite": true},
+	}
+	SetWriteDryRun(true)
+	defer SetWriteDryRun(false)
+	assert.NoError(t, writeCoils(coil, &sdk.WriteData{Data: []byte("1")}))
+
+	// Reads go to the device, so toggles and bit writes work, but writes do not.
+	client := newFakeClient()
+	client.coils[3] = true
+	coilData := &config.ModbusDeviceData{Address: 3}
+	assert.NoError(t, writeCoilData(newDryRunClient(client, coil, coilData), coilData, &sdk.WriteData{Action: "toggle"}))
+	assert.Equal(t, []string{"ReadCoils"}, client.calls)
+	assert.True(t, client.coils[3])
+
+	client = newFakeClient()
+	bit := uint16(2)
+	bitData := &config.ModbusDeviceData{Address: 10, Width: 1, Type: "b", Bit: &bit}
+	assert.NoError(t, writeHoldingRegisterData(newDryRunClient(client, device, bitData), bitData, &sdk.WriteData{Data: []byte("1")}))
+	assert.Empty(t, client.calls)
+	assert.Empty(t, client.registers)
+}
+
+// The device is read right after a write and its readings are published,
+// transformed and with the device context, without waiting for a read cycle.
+func TestReadAfterWrite(t *testing.T) {
+	ResetRangeViolations()
+	client := newFakeClient()
+	newClient = func(deviceData *config.ModbusDeviceData) (modbus.Client, *modbus.TCPClientHandler, error) {
+		return client, modbus.NewTCPClientHandler("localhost:502"), nil
+	}
+	defer func() { newClient = utils.NewClient }()
+
+	device := &sdk.Device{
+		Info: "setpoint",
+		Data: map[string]interface{}{
+			"host": "localhost", "port": 1502, "timeout": "1s",
+			"address": 10, "width": 2, "type": "u32", "typedWrite": true,
+		},
+		Output:     "number",
+		Handler:    "holding_register",
+		Context:    map[string]string{"zone": "a"},
+		Transforms: []sdk.Transformer{&sdk.ScaleTransformer{Factor: 2}},
+	}
+	coil := &sdk.Device{
+		Info:    "relay",
+		Data:    map[string]interface{}{"host": "localhost", "port": 1502, "timeout": "1s", "address": 3, "width": 1},
+		Output:  "switch",
+		Handler: "coil",
+	}
+
+	// Nothing is read until the listener has the reading channel.
+	assert.NoError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("65538")}))
+	assert.Equal(t, []string{"WriteMultipleRegisters"}, client.calls)
+
+	readings := make(chan *sdk.ReadContext, 2)
+	assert.NoError(t, listenForWrites(device, readings))
+	defer func() { publishReadings = nil }()
+
+	client.calls = nil
+	assert.NoError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("65539")}))
+	assert.NoError(t, writeCoils(coil, &sdk.WriteData{Data: []byte("1")}))
+	assert.Equal(t, []string{"WriteMultipleRegisters", "ReadHoldingRegisters", "WriteSingleCoil", "ReadCoils"}, client.calls)
+	assert.Len(t, readings, 2)
+	readContext := <-readings
+	assert.Equal(t, device, readContext.Device)
+	assert.Equal(t, float64(2*0x00010003), readContext.Reading[0].Value)
+	assert.Equal(t, map[string]string{"zone": "a"}, readContext.Reading[0].Context)
+	readContext = <-readings
+	assert.Equal(t, coil, readContext.Device)
+	assert.Equal(t, true, readContext.Reading[0].Value)
+
+	// Readings after writes are limited to the range without counting range
+	// violations, and devices with a rate are not read.
+	device.Data["max"] = 10
+	device.Data["rangeAction"] = "clamp"
+	device.Transforms = nil
+	assert.NoError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("5")}))
+	client.registers[11] = 0x20
+	readAfterWrite(client, device, &config.ModbusDeviceData{}, false)
+	readContext = <-readings
+	assert.Equal(t, uint32(5), readContext.Reading[0].Value)
+	readContext = <-readings
+	assert.Equal(t, uint32(10), readContext.Reading[0].Value)
+	assert.Equal(t, uint64(0), GetRangeViolationCount(device))
+	client.calls = nil
+	readAfterWrite(client, device, &config.ModbusDeviceData{Rate: &config.RateOptions{}}, false)
+	assert.Empty(t, client.calls)
+
+	// Failed reads and a full queue are not published.
+	client.errors["ReadHoldingRegisters"] = fmt.Errorf("timeout")
+	assert.NoError(t, writeHoldingRegister(device, &sdk.WriteData{Data: []byte("5")}))
+	assert.Len(t, readings, 0)
+	readings <- &sdk.ReadContext{}
+	readings <- &sdk.ReadContext{}
+	assert.NoError(t, writeCoils(coil, &sdk.WriteData{Data: []byte("0")}))
+	assert.Len(t, readings, 2)
+}
+
+// The write preamble and postamble are made around writes which are sent.
+func TestWrite_Sequences(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	deviceData := &config.ModbusDeviceData{
+		Address: 0x20, Type: "u16", Width: 1, WriteMax: &[]float64{100}[0],
+		WritePreamble:  []config.RegisterWrite{{Address: 0x100, Values: []uint16{0x1234, 0x5678}, Delay: "100ms"}},
+		WritePostamble: []config.RegisterWrite{{Address: 0x100, Values: []uint16{0}}},
+	}
+	client := newFakeClient()
+	assert.NoError(t, writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte("2a")}))
+	assert.Equal(t, []string{"WriteMultipleRegisters", "WriteSingleRegister", "WriteSingleRegister"}, client.calls)
+	assert.Equal(t, map[uint16]uint16{0x20: 0x2a, 0x100: 0, 0x101: 0x5678}, client.registers)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond}, slept)
+
+	// A rejected write sends nothing.
+	client = newFakeClient()
+	assert.Error(t, writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte("ff")}))
+	assert.Empty(t, client.calls)
+
+	// The postamble is made after a failed write and a failed postamble fails the write.
+	client = newFakeClient()
+	client.errors["WriteSingleRegister"] = fmt.Errorf("timeout")
+	assert.EqualError(t, writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte("2a")}), "timeout")
+	assert.Equal(t, []string{"WriteMultipleRegisters", "WriteSingleRegister", "WriteSingleRegister"}, client.calls)
+
+	deviceData.WritePostamble[0].Values = []uint16{0, 0}
+	failing := &failingPostambleClient{fakeClient: newFakeClient()}
+	assert.EqualError(t, writeHoldingRegisterData(failing, deviceData, &sdk.WriteData{Data: []byte("2a")}),
+		"writePostamble: write of register 0x100: timeout")
+
+	// Reads are made without the preamble.
+	client = newFakeClient()
+	assert.NoError(t, writeCoilData(client, deviceData, &sdk.WriteData{Action: "toggle"}))
+	assert.Equal(t, []string{"ReadCoils", "WriteMultipleRegisters", "WriteSingleCoil", "WriteMultipleRegisters"}, client.calls)
+}
+
+// failingPostambleClient fails the second write multiple registers, which is
+// the postamble.
+type failingPostambleClient struct {
+	*fakeClient
+	writes int
+}
+
+func (c *failingPostambleClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	c.writes++
+	if c.writes == 2 {
+		return nil, fmt.Errorf("timeout")
+	}
+	return c.fakeClient.WriteMultipleRegisters(address, quantity, value)
+}
+
+// statusClient reports the next of statuses at each read of the status register.
+type statusClient struct {
+	*fakeClient
+	statuses []uint16
+}
+
+func (c *statusClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	if len(c.statuses) > 0 {
+		c.registers[address] = c.statuses[0]
+		c.statuses = c.statuses[1:]
+	}
+	return c.fakeClient.ReadHoldingRegisters(address, quantity)
+}
+
+// A command write polls the status register until the command is done or fails.
+func TestWriteHoldingRegisterData_Handshake(t *testing.T) {
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	sleep = func(d time.Duration) { clock = clock.Add(d) }
+	defer func() { now, sleep = time.Now, time.Sleep }()
+
+	deviceData := &config.ModbusDeviceData{
+		Address: 0x10, Type: "u16", Width: 1,
+		Handshake: &config.HandshakeOptions{StatusAddress: 0x11, Done: []uint16{2}, Error: []uint16{3, 4},
+			PollInterval: "200ms", Timeout: "1s"},
+	}
+
+	var tests = []struct {
+		name     string
+		statuses []uint16
+		readErr  error
+		message  string
+		reads    int
+	}{
+		{name: "done", statuses: []uint16{1, 1, 2}, reads: 3},
+		{name: "failed", statuses: []uint16{1, 4}, message: "command failed: status register 0x11 is 4 (0x4)", reads: 2},
+		{name: "timeout", statuses: []uint16{1}, message: "command not done after 1s: status register 0x11, last status 1 (0x1)", reads: 5},
+		{name: "read failed", readErr: fmt.Errorf("timeout"), message: "command not done after 1s: status register 0x11, status read failed: timeout", reads: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &statusClient{fakeClient: newFakeClient(), statuses: tt.statuses}
+			client.errors["ReadHoldingRegisters"] = tt.readErr
+			err := writeHoldingRegisterData(client, deviceData, &sdk.WriteData{Data: []byte("1")})
+			if tt.message == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.message)
+			}
+			assert.Equal(t, uint16(1), client.registers[0x10])
+			assert.Equal(t, 1+tt.reads, len(client.calls))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package devices
+
+import (
+	"fmt"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+)
+
+// writeDryRun is true when no device may be written.
+var writeDryRun bool
+
+// SetWriteDryRun turns the plugin level write dry run on or off. In a dry run,
+// writes are validated and encoded and the requests which would have been sent
+// are logged, but no write is sent to any device.
+func SetWriteDryRun(enabled bool) {
+	writeDryRun = enabled
+	if enabled {
+		log.Warn("Write dry run is on, no writes will be sent to devices")
+	}
+}
+
+// isDryRun returns true if writes to the device are a dry run.
+func isDryRun(deviceData *config.ModbusDeviceData) bool {
+	return writeDryRun || deviceData.DryRun
+}
+
+// dryRunDeviceData gets the device data for a dry run write, which does not
+// read back the written value or wait for a command status.
+func dryRunDeviceData(deviceData *config.ModbusDeviceData) *config.ModbusDeviceData {
+	dryRunData := *deviceData
+	dryRunData.VerifyWrite = false
+	dryRunData.Handshake = nil
+	return &dryRunData
+}
+
+// dryRunClient is a modbus client which logs the writes it would have sent
+// and replies as the device would, without sending them. Reads go to the
+// device, so that writes which read first (e.g. toggle) work as usual.
+type dryRunClient struct {
+	modbus.Client
+	device     *sdk.Device
+	deviceData *config.ModbusDeviceData
+}
+
+// newDryRunClient makes a dry run client for writes to device, which reads
+// with client.
+func newDryRunClient(client modbus.Client, device *sdk.Device, deviceData *config.ModbusDeviceData) *dryRunClient {
+	return &dryRunClient{Client: client, device: device, deviceData: deviceData}
+}
+
+// logWrite logs the request which would have been sent.
+func (c *dryRunClient) logWrite(function string, address uint16, payload []byte) {
+	fields := log.Fields{
+		"host":     c.deviceData.Host,
+		"port":     c.deviceData.Port,
+		"unit":     c.deviceData.SlaveID,
+		"function": function,
+		"address":  fmt.Sprintf("0x%x", address),
+		"payload":  fmt.Sprintf("%x", payload),
+	}
+	if c.device != nil {
+		fields["device"] = c.device.GetID()
+		fields["info"] = c.device.Info
+	}
+	log.WithFields(fields).Info("[modbus write dry run]: not sent")
+}
+
+// ReadWriteMultipleRegisters logs the write and only makes the read.
+func (c *dryRunClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
+	c.logWrite("ReadWriteMultipleRegisters", writeAddress, value)
+	return c.Client.ReadHoldingRegisters(readAddress, readQuantity)
+}
+
+func (c *dryRunClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	payload := []byte{byte(value >> 8), byte(value)}
+	c.logWrite("WriteSingleCoil", address, payload)
+	return payload, nil
+}
+
+func (c *dryRunClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	c.logWrite("WriteMultipleCoils", address, value)
+	return []byte{byte(quantity >> 8), byte(quantity)}, nil
+}
+
+func (c *dryRunClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	payload := []byte{byte(value >> 8), byte(value)}
+	c.logWrite("WriteSingleRegister", address, payload)
+	return payload, nil
+}
+
+func (c *dryRunClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	c.logWrite("WriteMultipleRegisters", address, value)
+	return []byte{byte(quantity >> 8), byte(quantity)}, nil
+}
+
+func (c *dryRunClient) MaskWriteRegister(address, andMask, orMask uint16) ([]byte, error) {
+	payload := []byte{byte(andMask >> 8), byte(andMask), byte(orMask >> 8), byte(orMask)}
+	c.logWrite("MaskWriteRegister", address, payload)
+	return payload, nil
+}
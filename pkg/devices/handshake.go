@@ -0,0 +1,95 @@
+package devices
+
+import (
+	"fmt"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+)
+
+// validateHandshake checks the command/status handshake in the device data.
+func validateHandshake(deviceData *config.ModbusDeviceData, handler string) error {
+	handshake := deviceData.Handshake
+	if handshake == nil {
+		return nil
+	}
+	if handler != "holding_register" {
+		return fmt.Errorf("'handshake' is not supported for the %s handler", handler)
+	}
+	if deviceData.Bit != nil {
+		return fmt.Errorf("'handshake' is not supported with 'bit'")
+	}
+	if len(handshake.Done) == 0 {
+		return fmt.Errorf("'handshake' needs at least one 'done' status")
+	}
+	for _, done := range handshake.Done {
+		for _, failed := range handshake.Error {
+			if done == failed {
+				return fmt.Errorf("'handshake' status %d is both 'done' and 'error'", done)
+			}
+		}
+	}
+	if interval, err := handshake.GetPollInterval(); err != nil || interval <= 0 {
+		return fmt.Errorf("'handshake' 'pollInterval' %q must be a positive duration", handshake.PollInterval)
+	}
+	if timeout, err := handshake.GetTimeout(); err != nil || timeout <= 0 {
+		return fmt.Errorf("'handshake' 'timeout' %q must be a positive duration", handshake.Timeout)
+	}
+	return nil
+}
+
+// awaitCommand polls the status register after a command write until it
+// reports the command done or failed, or the handshake times out. Failed
+// status reads are retried until the timeout.
+func awaitCommand(client modbus.Client, handshake *config.HandshakeOptions) error {
+	interval, err := handshake.GetPollInterval()
+	if err != nil {
+		return err
+	}
+	timeout, err := handshake.GetTimeout()
+	if err != nil {
+		return err
+	}
+
+	address := handshake.StatusAddress
+	deadline := now().Add(timeout)
+	var lastStatus string
+	for {
+		sleep(interval)
+
+		results, err := client.ReadHoldingRegisters(address, 1)
+		incrementModbusCallCounter()
+		log.Debugf("[modbus call]: ReadHoldingRegisters(0x%x, 1), result: %x, err: %v", address, results, err)
+		switch {
+		case err != nil:
+			lastStatus = fmt.Sprintf("status read failed: %v", err)
+		case len(results) != 2:
+			lastStatus = fmt.Sprintf("status read returned %d bytes", len(results))
+		default:
+			status := uint16(results[0])<<8 | uint16(results[1])
+			if containsStatus(handshake.Done, status) {
+				log.Debugf("Command done, status register 0x%x is 0x%x", address, status)
+				return nil
+			}
+			if containsStatus(handshake.Error, status) {
+				return fmt.Errorf("command failed: status register 0x%x is %d (0x%x)", address, status, status)
+			}
+			lastStatus = fmt.Sprintf("last status %d (0x%x)", status, status)
+		}
+
+		if !now().Before(deadline) {
+			return fmt.Errorf("command not done after %v: status register 0x%x, %s", timeout, address, lastStatus)
+		}
+	}
+}
+
+// containsStatus returns true if status is one of statuses.
+func containsStatus(statuses []uint16, status uint16) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/goburrow/modbus"
 	log "github.com/sirupsen/logrus"
 	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
 	"github.com/vapor-ware/synse-sdk/v2/sdk"
 )
 
@@ -16,6 +17,7 @@ var HoldingRegisterHandler = sdk.DeviceHandler{
 	Name:     "holding_register",
 	BulkRead: bulkReadHoldingRegisters,
 	Write:    writeHoldingRegister,
+	Listen:   listenForWrites,
 }
 
 // ReadOnlyHoldingRegisterHandler is a handler which should be used for all devices/outputs
@@ -32,6 +34,7 @@ func bulkReadHoldingRegisters(devices []*sdk.Device) (readContexts []*sdk.ReadCo
 
 	// Call SetupBulkRead in case it's not setup, then get the bulk read map for holding registers.
 	SetupBulkRead()
+	defer func() { readContexts, err = endBulkRead("holding", readContexts, err) }()
 	bulkReadMap, keyOrder, err := GetBulkReadMap("holding")
 	if err != nil {
 		return
@@ -111,22 +114,104 @@ func writeHoldingRegister(device *sdk.Device, data *sdk.WriteData) (err error) {
 	if err != nil {
 		return err
 	}
+	defer handler.Close()
+	if isDryRun(deviceData) {
+		dryRunData := dryRunDeviceData(deviceData)
+		return writeHoldingRegisterData(newDryRunClient(*client, device, dryRunData), dryRunData, data)
+	}
+	if coalescable(deviceData, data) {
+		// Coalesced writes are read back on the connection of their batch.
+		return coalesceRegisterWrite(device, deviceData, data)
+	}
+	if err = writeHoldingRegisterData(newAuditClient(*client, device, deviceData), deviceData, data); err != nil {
+		return err
+	}
+
+	// Publish the written value without waiting for the next read.
+	readAfterWrite(*client, device, deviceData, false)
+	return nil
+}
+
+// writeHoldingRegisterData writes data to the holding register(s) of a device
+// with the given client.
+func writeHoldingRegisterData(client modbus.Client, deviceData *config.ModbusDeviceData, data *sdk.WriteData) (err error) {
+	if err = checkWritable(deviceData); err != nil {
+		return err
+	}
+
+	// The write preamble is made before the first write and the postamble after.
+	if hasSequences(deviceData) {
+		sequence := newSequenceClient(client, deviceData)
+		defer func() { err = sequence.finish(err) }()
+		client = sequence
+	}
+
+	// Bit devices only change their own bit of the register.
+	if deviceData.Bit != nil {
+		return writeBit(client, deviceData, string(data.Data))
+	}
+
+	register := deviceData.Address
+	payload, err := encodeRegisterWrite(deviceData, data)
+	if err != nil {
+		return err
+	}
+
+	if deviceData.TypedWrite {
+		log.Debugf("Writing holding registers 0x%x, count %d, data 0x%x", register, deviceData.Width, payload)
+		_, err = client.WriteMultipleRegisters(register, deviceData.Width, payload)
+	} else {
+		registerData := uint16(payload[0])<<8 | uint16(payload[1])
+		log.Debugf("Writing holding register 0x%x, data 0x%x", register, registerData)
+		_, err = client.WriteSingleRegister(register, registerData)
+	}
+	incrementModbusCallCounter()
+	if err != nil {
+		return err
+	}
+
+	if deviceData.VerifyWrite {
+		if err = verifyRegisters(client, deviceData, register, payload); err != nil {
+			return err
+		}
+	}
+
+	// Commands report their result in a status register.
+	if deviceData.Handshake != nil {
+		return awaitCommand(client, deviceData.Handshake)
+	}
+	return nil
+}
+
+// encodeRegisterWrite checks the write data against the write limits and
+// encodes it as the register bytes to write. Typed writes are encoded with
+// the device type and width. Otherwise the data is a hex uint16 for a single
+// register.
+func encodeRegisterWrite(deviceData *config.ModbusDeviceData, data *sdk.WriteData) (payload []byte, err error) {
+	if deviceData.TypedWrite {
+		if deviceData.StringOptions != nil && utils.IsStringType(deviceData.Type) {
+			payload, err = utils.EncodeString(string(data.Data), deviceData.Width, getStringOptions(deviceData))
+		} else {
+			payload, err = utils.EncodeValue(deviceData.Type, string(data.Data), deviceData.Width)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = checkWritePayload(deviceData, string(data.Data), payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	}
 
-	// Pull out the data to send on the wire from data.Data.
-	modbusData := data.Data
 	// Translate the data. This is currently a hex string.
-	dataString := string(modbusData)
+	dataString := string(data.Data)
 	register64, err := strconv.ParseUint(dataString, 16, 16)
 	if err != nil {
-		return fmt.Errorf("Unable to parse uint16 %v", dataString)
+		return nil, fmt.Errorf("Unable to parse uint16 %v", dataString)
 	}
 	registerData := uint16(register64)
-
-	// Modbus write.
-	register := deviceData.Address
-	log.Debugf("Writing holding register 0x%x, data 0x%x", register, registerData)
-	_, err = (*client).WriteSingleRegister(register, registerData)
-	handler.Close()
-	incrementModbusCallCounter()
-	return err
+	if err = checkWriteValue(deviceData, float64(registerData)); err != nil {
+		return nil, err
+	}
+	return []byte{byte(registerData >> 8), byte(registerData)}, nil
 }
@@ -21,6 +21,7 @@ func bulkReadInputRegisters(devices []*sdk.Device) (readContexts []*sdk.ReadCont
 
 	// Call SetupBulkRead in case it's not setup, then get the bulk read map for holding registers.
 	SetupBulkRead()
+	defer func() { readContexts, err = endBulkRead("input", readContexts, err) }()
 	bulkReadMap, keyOrder, err := GetBulkReadMap("input")
 
 	// Perform the bulk reads.
@@ -0,0 +1,83 @@
+package devices
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+)
+
+// validateWriteLimits checks the write limit configuration in the device data.
+func validateWriteLimits(deviceData *config.ModbusDeviceData) error {
+	if deviceData.WriteMin != nil && deviceData.WriteMax != nil && *deviceData.WriteMin > *deviceData.WriteMax {
+		return fmt.Errorf("'writeMin' %v is greater than 'writeMax' %v", *deviceData.WriteMin, *deviceData.WriteMax)
+	}
+	hasLimits := deviceData.WriteMin != nil || deviceData.WriteMax != nil || len(deviceData.WriteAllowed) > 0
+	if hasLimits && deviceData.TypedWrite && utils.IsStringType(deviceData.Type) {
+		return fmt.Errorf("'writeMin', 'writeMax' and 'writeAllowed' do not apply to 'type' %s", deviceData.Type)
+	}
+	return nil
+}
+
+// checkWritable returns an error if the device is configured as not writable.
+func checkWritable(deviceData *config.ModbusDeviceData) error {
+	if deviceData.Writable != nil && !*deviceData.Writable {
+		return fmt.Errorf("write rejected: device is not writable")
+	}
+	return nil
+}
+
+// checkWriteValue returns an error if a value is outside of the configured
+// write limits or not one of the allowed values.
+func checkWriteValue(deviceData *config.ModbusDeviceData, value float64) error {
+	if math.IsNaN(value) && (deviceData.WriteMin != nil || deviceData.WriteMax != nil) {
+		return fmt.Errorf("write rejected: value NaN is outside of the write limits")
+	}
+	if deviceData.WriteMin != nil && value < *deviceData.WriteMin {
+		return fmt.Errorf("write rejected: value %v is below writeMin %v", value, *deviceData.WriteMin)
+	}
+	if deviceData.WriteMax != nil && value > *deviceData.WriteMax {
+		return fmt.Errorf("write rejected: value %v is above writeMax %v", value, *deviceData.WriteMax)
+	}
+	if len(deviceData.WriteAllowed) == 0 {
+		return nil
+	}
+	for _, allowed := range deviceData.WriteAllowed {
+		if value == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("write rejected: value %v is not one of writeAllowed %v", value, deviceData.WriteAllowed)
+}
+
+// checkWriteState checks a coil or bit state, as 0 or 1, against the write limits.
+func checkWriteState(deviceData *config.ModbusDeviceData, state bool) error {
+	if state {
+		return checkWriteValue(deviceData, 1)
+	}
+	return checkWriteValue(deviceData, 0)
+}
+
+// checkWritePayload checks a typed write against the write limits, using the
+// value decoded from its payload. Single precision floats are checked using the
+// written text, so that rounding does not move a value at a limit outside of
+// it. Payloads which do not decode to a number (strings) are not limited.
+func checkWritePayload(deviceData *config.ModbusDeviceData, written string, payload []byte) error {
+	decoded, err := utils.CastToType(deviceData.Type, payload)
+	if err != nil {
+		return err
+	}
+	value, ok := utils.ToFloat64(decoded)
+	if !ok {
+		return nil
+	}
+	if _, isFloat32 := decoded.(float32); isFloat32 {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(written), 64); err == nil {
+			value = f
+		}
+	}
+	return checkWriteValue(deviceData, value)
+}
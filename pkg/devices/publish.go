@@ -0,0 +1,113 @@
+package devices
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+)
+
+// publishReadings is the channel readings made after writes are published to.
+// It is the SDK reading channel given to the listener, nil until then.
+var publishReadings chan *sdk.ReadContext
+var publishMutex sync.Mutex
+
+// listenForWrites is the listener for the writable device handlers. It does
+// not listen to the device, it only keeps the reading channel so that readings
+// made right after a write can be published.
+func listenForWrites(device *sdk.Device, readings chan *sdk.ReadContext) error {
+	publishMutex.Lock()
+	publishReadings = readings
+	publishMutex.Unlock()
+	return nil
+}
+
+// readAfterWrite reads a device right after a write on the write connection,
+// out of the read cycle, and publishes its readings so that the written value
+// is seen before the next read interval. Devices with a rate are not read, as
+// their rate reading only comes from the read cycle. Failures are logged and
+// do not fail the write.
+func readAfterWrite(client modbus.Client, device *sdk.Device, deviceData *config.ModbusDeviceData, isCoil bool) {
+	publishMutex.Lock()
+	readings := publishReadings
+	publishMutex.Unlock()
+	if readings == nil {
+		log.Debugf("Not reading device %q after write, listening is disabled", device.Info)
+		return
+	}
+	if deviceData.Rate != nil {
+		return
+	}
+
+	readContexts, err := readDevice(client, device, isCoil)
+	if err != nil {
+		log.Warnf("Failed to read device %q after write: %v", device.Info, err)
+		return
+	}
+
+	for _, readContext := range readContexts {
+		if err = finalizeReadings(readContext); err != nil {
+			log.Errorf("Discarding readings of device %q after write: %v", readContext.Device.Info, err)
+			continue
+		}
+		select {
+		case readings <- readContext:
+			log.Debugf("Published readContext after write: %#v, device: %v", readContext, readContext.Device)
+		default:
+			log.Warnf("Reading queue is full, not publishing readings of device %q after write", readContext.Device.Info)
+		}
+	}
+}
+
+// finalizeReadings applies the device transforms and adds the device context
+// to the readings, as the SDK does for readings from the read cycle. The SDK
+// does not finalize readings from listeners.
+func finalizeReadings(readContext *sdk.ReadContext) error {
+	for _, reading := range readContext.Reading {
+		if reading.Value != nil {
+			for _, transformer := range readContext.Device.Transforms {
+				if err := transformer.Apply(reading); err != nil {
+					return fmt.Errorf("transform %s: %v", transformer.Name(), err)
+				}
+			}
+		}
+		reading.WithContext(readContext.Device.Context)
+	}
+	return nil
+}
+
+// readDevice makes the bulk reads for a single device with the client and maps
+// the results to read contexts. The results are mapped outside of the read
+// cycle, so range violations, rates and computed devices are not updated.
+func readDevice(client modbus.Client, device *sdk.Device, isCoil bool) (readContexts []*sdk.ReadContext, err error) {
+	bulkReadMap, keyOrder, err := MapBulkRead([]*sdk.Device{device}, isCoil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range keyOrder {
+		for _, read := range bulkReadMap[k] {
+			var readResults []byte
+			if isCoil {
+				readResults, err = client.ReadCoils(read.StartRegister, read.RegisterCount)
+			} else {
+				readResults, err = client.ReadHoldingRegisters(read.StartRegister, read.RegisterCount)
+			}
+			incrementModbusCallCounter()
+			log.Debugf("[modbus call]: read after write (coil: %v) at 0x%x, count 0x%x, result: %x, err: %v",
+				isCoil, read.StartRegister, read.RegisterCount, readResults, err)
+			if err != nil {
+				return nil, err
+			}
+			if !isCoil && len(readResults) < 2*int(read.RegisterCount) { // Two bytes per register.
+				return nil, fmt.Errorf("read of %d registers at 0x%x returned %d bytes",
+					read.RegisterCount, read.StartRegister, len(readResults))
+			}
+			read.ReadResults = readResults
+		}
+	}
+	return mapBulkReadData(bulkReadMap, keyOrder, false)
+}
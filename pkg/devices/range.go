@@ -0,0 +1,141 @@
+package devices
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+	"github.com/vapor-ware/synse-sdk/v2/sdk/output"
+)
+
+// rangeViolations counts the readings outside of the configured min / max for
+// each device. rangeLogged is true for a device once its current run of
+// violations has been logged, so that we log once rather than every read.
+var rangeViolations = make(map[*sdk.Device]uint64)
+var rangeLogged = make(map[*sdk.Device]bool)
+var rangeMutex sync.Mutex
+
+// GetRangeViolationCount gets the number of readings for the device which
+// were outside of its configured min / max.
+func GetRangeViolationCount(device *sdk.Device) (count uint64) {
+	rangeMutex.Lock()
+	count = rangeViolations[device]
+	rangeMutex.Unlock()
+	return
+}
+
+// ResetRangeViolations resets the range violation counts for test purposes.
+func ResetRangeViolations() {
+	rangeMutex.Lock()
+	rangeViolations = make(map[*sdk.Device]uint64)
+	rangeLogged = make(map[*sdk.Device]bool)
+	rangeMutex.Unlock()
+}
+
+// validateRange checks the min / max configuration in the device data.
+func validateRange(deviceData *config.ModbusDeviceData) error {
+	if deviceData.Min != nil && deviceData.Max != nil && *deviceData.Min > *deviceData.Max {
+		return fmt.Errorf("'min' %v is greater than 'max' %v", *deviceData.Min, *deviceData.Max)
+	}
+	switch deviceData.RangeAction {
+	case "", "drop", "clamp":
+		return nil
+	}
+	return fmt.Errorf("'rangeAction' must be drop or clamp, is %q", deviceData.RangeAction)
+}
+
+// applyRange checks a reading against the min / max in the device data with
+// limitReading, and counts and logs the readings which were out of range.
+func applyRange(device *sdk.Device, deviceData *config.ModbusDeviceData, reading *output.Reading) {
+	if reading == nil {
+		return
+	}
+	value := reading.Value
+	if !limitReading(deviceData, reading) {
+		// In range. Log the next violation for this device.
+		rangeMutex.Lock()
+		delete(rangeLogged, device)
+		rangeMutex.Unlock()
+		return
+	}
+
+	rangeMutex.Lock()
+	rangeViolations[device]++
+	count := rangeViolations[device]
+	logged := rangeLogged[device]
+	rangeLogged[device] = true
+	rangeMutex.Unlock()
+
+	if !logged {
+		log.Warnf("Reading out of range for device %q: value %v, min %v, max %v, action %q (%d out of range so far)",
+			device.Info, value, floatString(deviceData.Min), floatString(deviceData.Max),
+			deviceData.RangeAction, count)
+	}
+}
+
+// limitReading checks a reading against the min / max in the device data. A
+// value outside of the range is dropped (the reading value becomes nil) or
+// clamped to the limit, depending on the range action. NaN is never in range
+// and is always dropped. Non-numeric and nil values are left alone. Returns
+// true when the reading was out of range.
+func limitReading(deviceData *config.ModbusDeviceData, reading *output.Reading) bool {
+	if reading == nil || (deviceData.Min == nil && deviceData.Max == nil) {
+		return false
+	}
+	value, ok := utils.ToFloat64(reading.Value)
+	if !ok {
+		return false
+	}
+
+	below := deviceData.Min != nil && value < *deviceData.Min
+	above := deviceData.Max != nil && value > *deviceData.Max
+	if !below && !above && !math.IsNaN(value) {
+		return false
+	}
+
+	if deviceData.RangeAction != "clamp" || math.IsNaN(value) {
+		reading.Value = nil
+		return true
+	}
+
+	var limit float64
+	if below {
+		limit = *deviceData.Min
+	} else {
+		limit = *deviceData.Max
+	}
+	switch reading.Value.(type) {
+	case float32, float64:
+	default:
+		// Integer readings are clamped to the nearest integer inside the range.
+		if below {
+			limit = math.Ceil(limit)
+		} else {
+			limit = math.Floor(limit)
+		}
+	}
+	reading.Value = utils.FromFloat64(limit, reading.Value)
+	return true
+}
+
+// checkRange applies the range in the device data to a reading. Only readings
+// from the read cycle count towards the range violations of the device.
+func checkRange(device *sdk.Device, deviceData *config.ModbusDeviceData, reading *output.Reading, inCycle bool) {
+	if inCycle {
+		applyRange(device, deviceData, reading)
+		return
+	}
+	limitReading(deviceData, reading)
+}
+
+// floatString formats an optional limit for logging.
+func floatString(f *float64) string {
+	if f == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%v", *f)
+}
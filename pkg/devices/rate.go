@@ -0,0 +1,147 @@
+package devices
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+	"github.com/vapor-ware/synse-sdk/v2/sdk/output"
+)
+
+// counterMaximums are the values at which counter types roll over to zero.
+var counterMaximums = map[string]float64{
+	"u16":       math.MaxUint16,
+	"uint16":    math.MaxUint16,
+	"u32":       math.MaxUint32,
+	"uint32":    math.MaxUint32,
+	"u64":       math.MaxUint64,
+	"uint64":    math.MaxUint64,
+	"u32m10":    1e8 - 1,
+	"uint32m10": 1e8 - 1,
+	"u48m10":    1e12 - 1,
+	"uint48m10": 1e12 - 1,
+	"u64m10":    1e16 - 1,
+	"uint64m10": 1e16 - 1,
+}
+
+// counterSample is the previous reading of a counter.
+type counterSample struct {
+	value float64
+	time  time.Time
+}
+
+// counterSamples holds the previous reading of each rate device.
+var counterSamples = make(map[*sdk.Device]counterSample)
+var counterMutex sync.Mutex
+
+// now is time.Now, swapped out by tests.
+var now = time.Now
+
+// ResetCounterSamples forgets all previous counter readings for test purposes.
+func ResetCounterSamples() {
+	counterMutex.Lock()
+	counterSamples = make(map[*sdk.Device]counterSample)
+	counterMutex.Unlock()
+}
+
+// validateRate checks the rate configuration in the device data.
+func validateRate(deviceData *config.ModbusDeviceData) error {
+	if deviceData.Rate == nil {
+		return nil
+	}
+	if _, _, err := ratePer(deviceData.Rate); err != nil {
+		return err
+	}
+	if deviceData.Rate.Output != "" && output.Get(deviceData.Rate.Output) == nil {
+		return fmt.Errorf("'rate' output %q is not registered", deviceData.Rate.Output)
+	}
+	return nil
+}
+
+// ratePer gets the rate unit name and the number of seconds in it.
+func ratePer(rate *config.RateOptions) (unit string, seconds float64, err error) {
+	switch strings.ToLower(rate.Per) {
+	case "", "second":
+		return "second", 1, nil
+	case "minute":
+		return "minute", 60, nil
+	case "hour":
+		return "hour", 3600, nil
+	}
+	return "", 0, fmt.Errorf("'rate' per must be second, minute or hour, is %q", rate.Per)
+}
+
+// makeRateReading derives the rate of change of a counter from its current
+// reading and the previous one. The rate reading has a nil value on the first
+// read, when the counter reading has no value, and after a device reset. A
+// counter which decreases is taken to have rolled over if that is the smaller
+// change, otherwise it is taken to have been reset.
+func makeRateReading(device *sdk.Device, deviceData *config.ModbusDeviceData, counterOutput *output.Output, counter *output.Reading) (
+	reading *output.Reading, err error) {
+
+	rateOutput := counterOutput
+	if deviceData.Rate.Output != "" {
+		rateOutput = output.Get(deviceData.Rate.Output)
+	}
+	unit, per, err := ratePer(deviceData.Rate)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := computeRate(device, deviceData.Type, counter, per)
+	if rate == nil {
+		reading, err = rateOutput.MakeReading(nil)
+	} else {
+		reading, err = rateOutput.MakeReading(*rate)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reading.WithContext(map[string]string{"rate": "per " + unit}), nil
+}
+
+// computeRate updates the counter sample for the device and returns the rate
+// per the given number of seconds, or nil if there is no rate.
+func computeRate(device *sdk.Device, typeName string, counter *output.Reading, per float64) *float64 {
+	if counter == nil {
+		return nil
+	}
+	value, ok := utils.ToFloat64(counter.Value)
+	if !ok || math.IsNaN(value) {
+		return nil
+	}
+	current := counterSample{value: value, time: now()}
+
+	counterMutex.Lock()
+	previous, havePrevious := counterSamples[device]
+	counterSamples[device] = current
+	counterMutex.Unlock()
+
+	if !havePrevious {
+		return nil
+	}
+	elapsed := current.time.Sub(previous.time).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+
+	delta := current.value - previous.value
+	if delta < 0 {
+		maximum, isCounter := counterMaximums[strings.ToLower(typeName)]
+		wrapped := maximum - previous.value + current.value + 1
+		if !isCounter || wrapped > maximum/2 {
+			log.Infof("Counter reset for device %q: %v to %v", device.Info, previous.value, current.value)
+			return nil
+		}
+		delta = wrapped
+	}
+
+	rate := delta / elapsed * per
+	return &rate
+}
@@ -0,0 +1,153 @@
+package devices
+
+import (
+	"fmt"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+)
+
+// validateSequences checks the write preamble and postamble in the device data.
+func validateSequences(deviceData *config.ModbusDeviceData) error {
+	sequences := []struct {
+		name   string
+		writes []config.RegisterWrite
+	}{
+		{"writePreamble", deviceData.WritePreamble},
+		{"writePostamble", deviceData.WritePostamble},
+	}
+	for _, sequence := range sequences {
+		name := sequence.name
+		for i, write := range sequence.writes {
+			if len(write.Values) == 0 || len(write.Values) > maxWriteRegisters {
+				return fmt.Errorf("'%s' write %d must have 1 to %d values", name, i, maxWriteRegisters)
+			}
+			if int(write.Address)+len(write.Values) > 65536 {
+				return fmt.Errorf("'%s' write %d runs past register 65535", name, i)
+			}
+			if delay, err := write.GetDelay(); err != nil || delay < 0 {
+				return fmt.Errorf("'%s' write %d 'delay' %q must be a duration of 0 or more", name, i, write.Delay)
+			}
+		}
+	}
+	return nil
+}
+
+// hasSequences returns true if the device has a write preamble or postamble.
+func hasSequences(deviceData *config.ModbusDeviceData) bool {
+	return len(deviceData.WritePreamble) > 0 || len(deviceData.WritePostamble) > 0
+}
+
+// sequenceClient is a modbus client which makes the write preamble of a device
+// before the first write made through it. Reads are made without it.
+type sequenceClient struct {
+	modbus.Client
+	deviceData *config.ModbusDeviceData
+	started    bool // The preamble was attempted.
+}
+
+// newSequenceClient wraps client to make the write preamble and postamble of
+// the device around its writes.
+func newSequenceClient(client modbus.Client, deviceData *config.ModbusDeviceData) *sequenceClient {
+	return &sequenceClient{Client: client, deviceData: deviceData}
+}
+
+// start makes the write preamble, once.
+func (c *sequenceClient) start() error {
+	if c.started {
+		return nil
+	}
+	c.started = true
+	return writeSequence(c.Client, "writePreamble", c.deviceData.WritePreamble)
+}
+
+// finish makes the write postamble if the preamble was attempted, even if it
+// or the write failed, and returns the result of the write. A postamble
+// failure fails an otherwise successful write.
+func (c *sequenceClient) finish(err error) error {
+	if !c.started || len(c.deviceData.WritePostamble) == 0 {
+		return err
+	}
+	postErr := writeSequence(c.Client, "writePostamble", c.deviceData.WritePostamble)
+	if postErr == nil {
+		return err
+	}
+	if err == nil {
+		return postErr
+	}
+	log.Errorf("Write failed, then %v", postErr)
+	return err
+}
+
+func (c *sequenceClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c.Client.WriteSingleCoil(address, value)
+}
+
+func (c *sequenceClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c.Client.WriteMultipleCoils(address, quantity, value)
+}
+
+func (c *sequenceClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c.Client.WriteSingleRegister(address, value)
+}
+
+func (c *sequenceClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c.Client.WriteMultipleRegisters(address, quantity, value)
+}
+
+func (c *sequenceClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c.Client.ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity, value)
+}
+
+func (c *sequenceClient) MaskWriteRegister(address, andMask, orMask uint16) ([]byte, error) {
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c.Client.MaskWriteRegister(address, andMask, orMask)
+}
+
+// writeSequence makes each register write of a write preamble or postamble,
+// stopping at the first failure.
+func writeSequence(client modbus.Client, name string, sequence []config.RegisterWrite) (err error) {
+	for _, write := range sequence {
+		log.Debugf("%s: writing holding registers 0x%x, data 0x%04x", name, write.Address, write.Values)
+		if len(write.Values) == 1 {
+			_, err = client.WriteSingleRegister(write.Address, write.Values[0])
+		} else {
+			payload := make([]byte, 0, 2*len(write.Values)) // Two bytes per register.
+			for _, value := range write.Values {
+				payload = append(payload, byte(value>>8), byte(value))
+			}
+			_, err = client.WriteMultipleRegisters(write.Address, uint16(len(write.Values)), payload)
+		}
+		incrementModbusCallCounter()
+		if err != nil {
+			return fmt.Errorf("%s: write of register 0x%x: %v", name, write.Address, err)
+		}
+
+		delay, err := write.GetDelay()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if delay > 0 {
+			sleep(delay)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package devices
+
+import (
+	"fmt"
+
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+	"github.com/vapor-ware/synse-sdk/v2/sdk"
+	"github.com/vapor-ware/synse-sdk/v2/sdk/output"
+)
+
+// maxRegisterAddress is the highest register address modbus can reference.
+const maxRegisterAddress = 0xffff
+
+// ValidateDevice checks that the modbus device data for a device is consistent
+// before any bulk read plan is built. The returned error names the device info
+// and the field at fault.
+func ValidateDevice(device *sdk.Device) error {
+	if device == nil {
+		return fmt.Errorf("device is nil")
+	}
+
+	// Computed devices have no registers of their own.
+	if device.Handler == "computed" {
+		return validateComputedDevice(device)
+	}
+
+	deviceData, err := config.ModbusDeviceDataFromDevice(device)
+	if err != nil {
+		return fmt.Errorf("device %q: failed to decode data: %v", device.Info, err)
+	}
+	return validateDeviceData(device, deviceData)
+}
+
+// validateDeviceData performs the checks for ValidateDevice on decoded device data.
+func validateDeviceData(device *sdk.Device, deviceData *config.ModbusDeviceData) error {
+	if err := deviceData.Validate(); err != nil {
+		return fmt.Errorf("device %q: %v", device.Info, err)
+	}
+
+	if output.Get(device.Output) == nil {
+		return fmt.Errorf("device %q: output %q is not registered", device.Info, device.Output)
+	}
+
+	// Coils are unpacked one bit at a time, so type and width do not apply.
+	isCoil := device.Handler == "coil" || device.Handler == "read_only_coil"
+
+	width := deviceData.Width
+	if isCoil && width == 0 {
+		width = 1
+	}
+
+	if len(deviceData.Addresses) > 0 {
+		if isCoil {
+			return fmt.Errorf("device %q: 'addresses' is not supported for coils", device.Info)
+		}
+		if width != 0 && int(width) != len(deviceData.Addresses) {
+			return fmt.Errorf("device %q: 'width' %d does not match the %d 'addresses'",
+				device.Info, width, len(deviceData.Addresses))
+		}
+		width = uint16(len(deviceData.Addresses))
+	} else if width == 0 {
+		return fmt.Errorf("device %q: 'width' must be at least 1", device.Info)
+	} else if uint32(deviceData.Address)+uint32(width)-1 > maxRegisterAddress {
+		return fmt.Errorf("device %q: 'address' %d with 'width' %d is beyond the last register %d",
+			device.Info, deviceData.Address, width, maxRegisterAddress)
+	}
+
+	if deviceData.Count > 1 {
+		if err := validateArray(deviceData, isCoil); err != nil {
+			return fmt.Errorf("device %q: %v", device.Info, err)
+		}
+	}
+
+	if deviceData.Bit != nil {
+		if err := validateBit(deviceData, device.Handler); err != nil {
+			return fmt.Errorf("device %q: %v", device.Info, err)
+		}
+	}
+
+	if isWritable(device.Handler) {
+		if err := validateWrites(deviceData, isCoil); err != nil {
+			return fmt.Errorf("device %q: %v", device.Info, err)
+		}
+	}
+
+	if err := validateHandshake(deviceData, device.Handler); err != nil {
+		return fmt.Errorf("device %q: %v", device.Info, err)
+	}
+
+	if isCoil {
+		return nil
+	}
+
+	t := utils.LookupType(deviceData.Type)
+	if t == nil {
+		return fmt.Errorf("device %q: unsupported 'type' %q", device.Info, deviceData.Type)
+	}
+	if t.Width != 0 && t.Width != width {
+		return fmt.Errorf("device %q: 'width' %d does not match 'type' %s, which is %d register(s) wide",
+			device.Info, width, deviceData.Type, t.Width)
+	}
+
+	if deviceData.TypedWrite {
+		if t.Encode == nil {
+			return fmt.Errorf("device %q: 'typedWrite' set for 'type' %s, which can not be written", device.Info, deviceData.Type)
+		}
+		if len(deviceData.Addresses) > 0 || deviceData.Count > 1 {
+			return fmt.Errorf("device %q: 'typedWrite' is not supported with 'addresses' or 'count'", device.Info)
+		}
+	}
+
+	if _, err := GetNotAvailable(deviceData); err != nil {
+		return fmt.Errorf("device %q: 'notAvailable': %v", device.Info, err)
+	}
+
+	if err := validateRange(deviceData); err != nil {
+		return fmt.Errorf("device %q: %v", device.Info, err)
+	}
+
+	if err := validateRate(deviceData); err != nil {
+		return fmt.Errorf("device %q: %v", device.Info, err)
+	}
+
+	if deviceData.StringOptions != nil {
+		if !utils.IsStringType(deviceData.Type) {
+			return fmt.Errorf("device %q: 'stringOptions' set for non-string 'type' %s", device.Info, deviceData.Type)
+		}
+		// Decoding nothing checks the options themselves.
+		if _, err := utils.DecodeString(nil, getStringOptions(deviceData)); err != nil {
+			return fmt.Errorf("device %q: 'stringOptions': %v", device.Info, err)
+		}
+	}
+	return nil
+}
+
+// isWritable returns true if the handler supports writes.
+func isWritable(handler string) bool {
+	return handler == "coil" || handler == "holding_register"
+}
+
+// validateWrites checks the device data which only applies to writes. The
+// pulse duration only applies to coils.
+func validateWrites(deviceData *config.ModbusDeviceData, isCoil bool) error {
+	if err := validateVerify(deviceData); err != nil {
+		return err
+	}
+	if err := validateCoalesce(deviceData); err != nil {
+		return err
+	}
+	if err := validateSequences(deviceData); err != nil {
+		return err
+	}
+	if err := validateWriteLimits(deviceData); err != nil {
+		return err
+	}
+	if !isCoil {
+		return nil
+	}
+	if pulse, err := deviceData.GetPulseDuration(); err != nil || pulse <= 0 {
+		return fmt.Errorf("'pulseDuration' %q must be a positive duration", deviceData.PulseDuration)
+	}
+	return nil
+}
+
+// validateArray checks the count and stride of an array device, which must
+// fit in a single bulk read.
+func validateArray(deviceData *config.ModbusDeviceData, isCoil bool) error {
+	if isCoil {
+		return fmt.Errorf("'count' is not supported for coils")
+	}
+	if len(deviceData.Addresses) > 0 {
+		return fmt.Errorf("'count' is not supported with 'addresses'")
+	}
+	if deviceData.Rate != nil {
+		return fmt.Errorf("'count' is not supported with 'rate'")
+	}
+	stride := uint32(deviceData.GetStride())
+	if stride < uint32(deviceData.Width) {
+		return fmt.Errorf("'stride' %d is less than 'width' %d", stride, deviceData.Width)
+	}
+	span := (uint32(deviceData.Count)-1)*stride + uint32(deviceData.Width)
+	if span > uint32(MaximumRegisterCount) {
+		return fmt.Errorf("'count' %d with 'stride' %d spans %d registers, more than the %d in a single read",
+			deviceData.Count, stride, span, MaximumRegisterCount)
+	}
+	if uint32(deviceData.Address)+span-1 > maxRegisterAddress {
+		return fmt.Errorf("'address' %d with 'count' %d is beyond the last register %d",
+			deviceData.Address, deviceData.Count, maxRegisterAddress)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package devices
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/goburrow/modbus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/config"
+)
+
+// sleep is time.Sleep, swapped out by tests.
+var sleep = time.Sleep
+
+// validateVerify checks the write verification configuration in the device data.
+func validateVerify(deviceData *config.ModbusDeviceData) error {
+	delay, err := deviceData.GetVerifyDelay()
+	if err != nil {
+		return fmt.Errorf("'verifyDelay': %v", err)
+	}
+	if delay < 0 {
+		return fmt.Errorf("'verifyDelay' %v is negative", delay)
+	}
+	return nil
+}
+
+// verifyRegisters reads back holding registers after a write and returns an
+// error if they do not hold the written data.
+func verifyRegisters(client modbus.Client, deviceData *config.ModbusDeviceData, address uint16, written []byte) error {
+	if err := verifyWait(deviceData); err != nil {
+		return err
+	}
+
+	count := uint16(len(written) / 2) // Two bytes per register.
+	readBack, err := client.ReadHoldingRegisters(address, count)
+	incrementModbusCallCounter()
+	log.Debugf("[modbus call]: ReadHoldingRegisters(0x%x, 0x%x), result: %x, err: %v", address, count, readBack, err)
+	if err != nil {
+		return fmt.Errorf("write verification failed: read back of register 0x%x: %v", address, err)
+	}
+	if !bytes.Equal(readBack, written) {
+		return fmt.Errorf("write verification failed: wrote 0x%x to register 0x%x, read back 0x%x", written, address, readBack)
+	}
+	return nil
+}
+
+// verifyCoil reads back a coil after a write and returns an error if it does
+// not hold the written state.
+func verifyCoil(client modbus.Client, deviceData *config.ModbusDeviceData, address uint16, written bool) error {
+	if err := verifyWait(deviceData); err != nil {
+		return err
+	}
+
+	readBack, err := client.ReadCoils(address, 1)
+	incrementModbusCallCounter()
+	log.Debugf("[modbus call]: ReadCoils(0x%x, 1), result: %x, err: %v", address, readBack, err)
+	if err != nil {
+		return fmt.Errorf("write verification failed: read back of coil 0x%x: %v", address, err)
+	}
+	if len(readBack) == 0 {
+		return fmt.Errorf("write verification failed: no data reading back coil 0x%x", address)
+	}
+	if state := readBack[0]&0x01 != 0; state != written {
+		return fmt.Errorf("write verification failed: wrote %v to coil 0x%x, read back %v", written, address, state)
+	}
+	return nil
+}
+
+// verifyWait waits for the configured verification delay.
+func verifyWait(deviceData *config.ModbusDeviceData) error {
+	delay, err := deviceData.GetVerifyDelay()
+	if err != nil {
+		return err
+	}
+	if delay > 0 {
+		sleep(delay)
+	}
+	return nil
+}
+
+// verifyBit reads back a holding register after a bit write and returns an
+// error if the bit does not hold the written state.
+func verifyBit(client modbus.Client, deviceData *config.ModbusDeviceData, address uint16, mask uint16, written bool) error {
+	if err := verifyWait(deviceData); err != nil {
+		return err
+	}
+
+	readBack, err := client.ReadHoldingRegisters(address, 1)
+	incrementModbusCallCounter()
+	log.Debugf("[modbus call]: ReadHoldingRegisters(0x%x, 1), result: %x, err: %v", address, readBack, err)
+	if err != nil {
+		return fmt.Errorf("write verification failed: read back of register 0x%x: %v", address, err)
+	}
+	if len(readBack) != 2 {
+		return fmt.Errorf("write verification failed: no data reading back register 0x%x", address)
+	}
+	if state := (uint16(readBack[0])<<8|uint16(readBack[1]))&mask != 0; state != written {
+		return fmt.Errorf("write verification failed: wrote %v to bit 0x%04x of register 0x%x, read back %v",
+			written, mask, address, state)
+	}
+	return nil
+}
@@ -1,9 +1,14 @@
 package pkg
 
 import (
+	"fmt"
+	"os"
+	"strconv"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/devices"
 	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/outputs"
+	_ "github.com/vapor-ware/synse-modbus-ip-plugin/pkg/vendors/cpm" // Register the busway CPM types.
 	"github.com/vapor-ware/synse-sdk/v2/sdk"
 )
 
@@ -36,6 +41,7 @@ func MakePlugin() *sdk.Plugin {
 		&devices.HoldingRegisterHandler,
 		&devices.ReadOnlyHoldingRegisterHandler,
 		&devices.InputRegisterHandler,
+		&devices.ComputedHandler,
 	)
 	if err != nil {
 		log.Fatal(err)
@@ -49,5 +55,66 @@ func MakePlugin() *sdk.Plugin {
 		log.Fatal(err)
 	}
 
+	// Configure the write dry run.
+	if err = configureWriteDryRun(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Configure the optional write audit file.
+	if err = configureWriteAudit(); err != nil {
+		log.Fatal(err)
+	}
+
 	return plugin
 }
+
+// Environment variables for the write audit file. The plugin configuration has
+// no section for plugin specific settings.
+const (
+	envWriteAuditFile     = "MODBUS_WRITE_AUDIT_FILE"
+	envWriteAuditMaxBytes = "MODBUS_WRITE_AUDIT_MAX_BYTES"
+	envWriteAuditMaxFiles = "MODBUS_WRITE_AUDIT_MAX_FILES"
+)
+
+// envWriteDryRun is the environment variable which turns the write dry run on
+// for all devices.
+const envWriteDryRun = "MODBUS_WRITE_DRY_RUN"
+
+// configureWriteDryRun sets the plugin level write dry run from the environment.
+func configureWriteDryRun() error {
+	value := os.Getenv(envWriteDryRun)
+	if value == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", envWriteDryRun, err)
+	}
+	devices.SetWriteDryRun(enabled)
+	return nil
+}
+
+// configureWriteAudit sets the write audit file from the environment. Writes
+// are always audited in the log.
+func configureWriteAudit() (err error) {
+	path := os.Getenv(envWriteAuditFile)
+	if path == "" {
+		return nil
+	}
+
+	maxBytes := int64(10 * 1024 * 1024)
+	if value := os.Getenv(envWriteAuditMaxBytes); value != "" {
+		if maxBytes, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("%s: %v", envWriteAuditMaxBytes, err)
+		}
+	}
+	maxFiles := 5
+	if value := os.Getenv(envWriteAuditMaxFiles); value != "" {
+		if maxFiles, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s: %v", envWriteAuditMaxFiles, err)
+		}
+	}
+
+	log.Infof("Auditing writes to %s", path)
+	return devices.SetWriteAuditFile(path, maxBytes, maxFiles)
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// EncodeValue encodes a value written to a device of the given type and width
+// as raw register bytes. Numeric types must encode to exactly width registers.
+// Strings are padded with NUL bytes to width registers.
+func EncodeValue(typeName string, value string, width uint16) ([]byte, error) {
+	t := LookupType(typeName)
+	if t == nil {
+		return nil, fmt.Errorf("unsupported output data type: %s", typeName)
+	}
+	if t.Encode == nil {
+		return nil, fmt.Errorf("type %s can not be written", typeName)
+	}
+
+	encoded, err := t.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	size := 2 * int(width) // Two bytes per register.
+	if IsStringType(typeName) && len(encoded) <= size {
+		return append(encoded, make([]byte, size-len(encoded))...), nil
+	}
+	if len(encoded) != size {
+		return nil, fmt.Errorf("%s value %q is %d bytes, width %d is %d bytes", typeName, value, len(encoded), width, size)
+	}
+	return encoded, nil
+}
+
+// EncodeString encodes a string written to a string type device of the given
+// width with the options used to decode it, the inverse of DecodeString. The
+// string is encoded with the character encoding and padded with NUL bytes to
+// width registers, then the bytes of each register are swapped if set.
+func EncodeString(value string, width uint16, opts *StringOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &StringOptions{}
+	}
+
+	var encoded []byte
+	encoding := strings.ToLower(opts.Encoding)
+	switch encoding {
+	case "", "utf8", "utf-8":
+		encoded = []byte(value)
+	case "ascii", "latin1", "iso-8859-1":
+		limit := rune(0xff)
+		if encoding == "ascii" {
+			limit = 0x7f
+		}
+		for _, r := range value {
+			if r > limit {
+				return nil, fmt.Errorf("%q can not be encoded as %s", r, opts.Encoding)
+			}
+			encoded = append(encoded, byte(r))
+		}
+	case "utf16", "utf-16", "utf16be", "utf16le":
+		var order binary.ByteOrder = binary.BigEndian
+		if encoding == "utf16le" {
+			order = binary.LittleEndian
+		}
+		for _, unit := range utf16.Encode([]rune(value)) {
+			b := make([]byte, 2)
+			order.PutUint16(b, unit)
+			encoded = append(encoded, b...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported string encoding: %s", opts.Encoding)
+	}
+
+	size := 2 * int(width) // Two bytes per register.
+	if len(encoded) > size {
+		return nil, fmt.Errorf("string value %q is %d bytes, width %d is %d bytes", value, len(encoded), width, size)
+	}
+	encoded = append(encoded, make([]byte, size-len(encoded))...)
+
+	if opts.ByteSwap {
+		for i := 0; i < len(encoded); i += 2 {
+			encoded[i], encoded[i+1] = encoded[i+1], encoded[i]
+		}
+	}
+	return encoded, nil
+}
+
+// EncodeUint encodes an unsigned integer of the given number of bytes. The
+// value may be decimal or 0x prefixed hex.
+func EncodeUint(value string, size int) ([]byte, error) {
+	u, err := strconv.ParseUint(strings.TrimSpace(value), 0, 8*size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %d-bit unsigned integer %q", 8*size, value)
+	}
+	return putUint(u, size), nil
+}
+
+// EncodeInt encodes a two's complement signed integer of the given number of
+// bytes. The value may be decimal or 0x prefixed hex.
+func EncodeInt(value string, size int) ([]byte, error) {
+	i, err := strconv.ParseInt(strings.TrimSpace(value), 0, 8*size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %d-bit signed integer %q", 8*size, value)
+	}
+	return putUint(uint64(i), size), nil
+}
+
+// EncodeFloat32 encodes an IEEE-754 single precision number.
+func EncodeFloat32(value string) ([]byte, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 32-bit floating point number %q", value)
+	}
+	return putUint(uint64(math.Float32bits(float32(f))), 4), nil
+}
+
+// EncodeFloat64 encodes an IEEE-754 double precision number.
+func EncodeFloat64(value string) ([]byte, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 64-bit floating point number %q", value)
+	}
+	return putUint(math.Float64bits(f), 8), nil
+}
+
+// EncodeCdabFloat32 encodes a 32-bit floating point number with the registers
+// swapped, the inverse of SwapCdabFloat32.
+func EncodeCdabFloat32(value string) ([]byte, error) {
+	b, err := EncodeFloat32(value)
+	if err != nil {
+		return nil, err
+	}
+	return []byte{b[2], b[3], b[0], b[1]}, nil
+}
+
+// putUint encodes the low size bytes of u in big endian order.
+func putUint(u uint64, size int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, u)
+	return b[8-size:]
+}
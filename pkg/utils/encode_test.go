@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Encoded values decode back to the written value.
+func TestEncodeValue(t *testing.T) {
+	var tests = []struct {
+		typeName string
+		width    uint16
+		value    string
+		encoded  []byte
+		decoded  interface{}
+	}{
+		{typeName: "u16", width: 1, value: "65535", encoded: []byte{0xff, 0xff}, decoded: uint16(65535)},
+		{typeName: "uint16", width: 1, value: "0x1234", encoded: []byte{0x12, 0x34}, decoded: uint16(0x1234)},
+		{typeName: "u32", width: 2, value: "305419896", encoded: []byte{0x12, 0x34, 0x56, 0x78}, decoded: uint32(0x12345678)},
+		{typeName: "u64", width: 4, value: "1", encoded: []byte{0, 0, 0, 0, 0, 0, 0, 1}, decoded: uint64(1)},
+		{typeName: "s16", width: 1, value: "-1", encoded: []byte{0xff, 0xff}, decoded: int16(-1)},
+		{typeName: "s32", width: 2, value: " -2 ", encoded: []byte{0xff, 0xff, 0xff, 0xfe}, decoded: int32(-2)},
+		{typeName: "s64", width: 4, value: "-3", encoded: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfd}, decoded: int64(-3)},
+		{typeName: "f32", width: 2, value: "21.5", encoded: []byte{0x41, 0xac, 0x00, 0x00}, decoded: float32(21.5)},
+		{typeName: "f64", width: 4, value: "-1.5", encoded: []byte{0xbf, 0xf8, 0, 0, 0, 0, 0, 0}, decoded: float64(-1.5)},
+		{typeName: "cdabswapf32", width: 2, value: "21.5", encoded: []byte{0x00, 0x00, 0x41, 0xac}, decoded: float32(21.5)},
+		{typeName: "t", width: 3, value: "abc", encoded: []byte{'a', 'b', 'c', 0, 0, 0}, decoded: "abc"},
+		{typeName: "t4", width: 2, value: "abcd", encoded: []byte{'a', 'b', 'c', 'd'}, decoded: "abcd"},
+	}
+
+	for _, tt := range tests {
+		encoded, err := EncodeValue(tt.typeName, tt.value, tt.width)
+		assert.NoError(t, err, tt.typeName)
+		assert.Equal(t, tt.encoded, encoded, tt.typeName)
+
+		decoded, err := CastToType(tt.typeName, encoded)
+		assert.NoError(t, err, tt.typeName)
+		assert.Equal(t, tt.decoded, decoded, tt.typeName)
+	}
+}
+
+func TestEncodeValue_Error(t *testing.T) {
+	var tests = []struct {
+		typeName string
+		width    uint16
+		value    string
+	}{
+		{typeName: "u16", width: 1, value: "65536"},
+		{typeName: "u16", width: 1, value: "-1"},
+		{typeName: "u16", width: 1, value: "one"},
+		{typeName: "u16", width: 2, value: "1"},
+		{typeName: "s16", width: 1, value: "32768"},
+		{typeName: "s32", width: 2, value: "1.0"},
+		{typeName: "f32", width: 2, value: "1e39"},
+		{typeName: "f64", width: 4, value: "warm"},
+		{typeName: "t", width: 1, value: "abc"},
+		{typeName: "bcd16", width: 1, value: "1"},
+		{typeName: "nope", width: 1, value: "1"},
+	}
+
+	for _, tt := range tests {
+		_, err := EncodeValue(tt.typeName, tt.value, tt.width)
+		assert.Error(t, err, "%s %s", tt.typeName, tt.value)
+	}
+}
+
+// Strings encoded with options decode back to the written value.
+func TestEncodeString(t *testing.T) {
+	var tests = []struct {
+		value   string
+		width   uint16
+		opts    *StringOptions
+		encoded []byte
+	}{
+		{value: "abc", width: 2, opts: nil, encoded: []byte{'a', 'b', 'c', 0}},
+		{value: "abc", width: 2, opts: &StringOptions{ByteSwap: true}, encoded: []byte{'b', 'a', 0, 'c'}},
+		{value: "é", width: 1, opts: &StringOptions{Encoding: "latin1"}, encoded: []byte{0xe9, 0}},
+		{value: "hi", width: 3, opts: &StringOptions{Encoding: "utf16"}, encoded: []byte{0, 'h', 0, 'i', 0, 0}},
+		{value: "hi", width: 2, opts: &StringOptions{Encoding: "utf16le", ByteSwap: true}, encoded: []byte{0, 'h', 0, 'i'}},
+	}
+
+	for _, tt := range tests {
+		encoded, err := EncodeString(tt.value, tt.width, tt.opts)
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.encoded, encoded, tt.value)
+
+		decoded, err := DecodeString(encoded, tt.opts)
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.value, decoded, tt.value)
+	}
+}
+
+func TestEncodeString_Error(t *testing.T) {
+	var tests = []struct {
+		value string
+		width uint16
+		opts  *StringOptions
+	}{
+		{value: "abc", width: 1, opts: nil},
+		{value: "hi", width: 1, opts: &StringOptions{Encoding: "utf16"}},
+		{value: "é", width: 1, opts: &StringOptions{Encoding: "ascii"}},
+		{value: "€", width: 1, opts: &StringOptions{Encoding: "latin1"}},
+		{value: "abc", width: 2, opts: &StringOptions{Encoding: "ebcdic"}},
+	}
+
+	for _, tt := range tests {
+		_, err := EncodeString(tt.value, tt.width, tt.opts)
+		assert.Error(t, err, tt.value)
+	}
+}
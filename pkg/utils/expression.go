@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// Expression is a parsed arithmetic expression over named variables, e.g.
+// "(a + b + c) / 1000". Numbers, variables, parentheses, unary minus and the
+// binary operators + - * / are supported.
+type Expression struct {
+	source    string
+	root      ast.Expr
+	variables []string
+}
+
+// ParseExpression parses an arithmetic expression.
+func ParseExpression(source string) (*Expression, error) {
+	root, err := parser.ParseExpr(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", source, err)
+	}
+
+	names := map[string]bool{}
+	if err = checkExpression(root, names); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %v", source, err)
+	}
+	variables := []string{}
+	for name := range names {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables)
+
+	return &Expression{source: source, root: root, variables: variables}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Variables returns the sorted names of the variables in the expression.
+func (e *Expression) Variables() []string {
+	return e.variables
+}
+
+// Evaluate evaluates the expression. Every variable in the expression must
+// have a value in vars.
+func (e *Expression) Evaluate(vars map[string]float64) (float64, error) {
+	return evaluate(e.root, vars)
+}
+
+// checkExpression checks that the parsed expression only uses supported syntax
+// and collects the variable names.
+func checkExpression(node ast.Expr, names map[string]bool) error {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		if n.Kind != token.INT && n.Kind != token.FLOAT {
+			return fmt.Errorf("unsupported literal %s", n.Value)
+		}
+		_, err := parseNumber(n)
+		return err
+	case *ast.Ident:
+		names[n.Name] = true
+		return nil
+	case *ast.ParenExpr:
+		return checkExpression(n.X, names)
+	case *ast.UnaryExpr:
+		if n.Op != token.SUB && n.Op != token.ADD {
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		return checkExpression(n.X, names)
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO:
+		default:
+			return fmt.Errorf("unsupported operator %s", n.Op)
+		}
+		if err := checkExpression(n.X, names); err != nil {
+			return err
+		}
+		return checkExpression(n.Y, names)
+	}
+	return fmt.Errorf("unsupported syntax at offset %d", node.Pos()-1)
+}
+
+// evaluate evaluates an expression checked by checkExpression.
+func evaluate(node ast.Expr, vars map[string]float64) (float64, error) {
+	switch n := node.(type) {
+	case *ast.BasicLit:
+		return parseNumber(n)
+	case *ast.Ident:
+		value, ok := vars[n.Name]
+		if !ok {
+			return 0, fmt.Errorf("no value for %s", n.Name)
+		}
+		return value, nil
+	case *ast.ParenExpr:
+		return evaluate(n.X, vars)
+	case *ast.UnaryExpr:
+		x, err := evaluate(n.X, vars)
+		if n.Op == token.SUB {
+			x = -x
+		}
+		return x, err
+	case *ast.BinaryExpr:
+		x, err := evaluate(n.X, vars)
+		if err != nil {
+			return 0, err
+		}
+		y, err := evaluate(n.Y, vars)
+		if err != nil {
+			return 0, err
+		}
+		switch n.Op {
+		case token.ADD:
+			return x + y, nil
+		case token.SUB:
+			return x - y, nil
+		case token.MUL:
+			return x * y, nil
+		case token.QUO:
+			if y == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return x / y, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported expression")
+}
+
+// parseNumber parses a numeric literal. Integers may be hex, octal or binary.
+func parseNumber(lit *ast.BasicLit) (float64, error) {
+	if lit.Kind == token.INT {
+		i, err := strconv.ParseInt(lit.Value, 0, 64)
+		return float64(i), err
+	}
+	return strconv.ParseFloat(lit.Value, 64)
+}
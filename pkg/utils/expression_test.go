@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpression_Evaluate(t *testing.T) {
+	vars := map[string]float64{"a": 1.5, "b": 2, "c": -4, "watts": 900, "va": 1000}
+
+	var tests = []struct {
+		expression string
+		variables  []string
+		expected   float64
+	}{
+		{expression: "a + b + c", variables: []string{"a", "b", "c"}, expected: -0.5},
+		{expression: "watts / va", variables: []string{"va", "watts"}, expected: 0.9},
+		{expression: "(a + b) * 2", variables: []string{"a", "b"}, expected: 7},
+		{expression: "a + b * 2", variables: []string{"a", "b"}, expected: 5.5},
+		{expression: "-c - -a", variables: []string{"a", "c"}, expected: 5.5},
+		{expression: "a - a", variables: []string{"a"}, expected: 0},
+		{expression: "0x10 + 1e3 + .5", variables: []string{}, expected: 1016.5},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseExpression(tt.expression)
+		assert.NoError(t, err, tt.expression)
+		assert.Equal(t, tt.expression, e.String())
+		assert.Equal(t, tt.variables, e.Variables(), tt.expression)
+
+		value, err := e.Evaluate(vars)
+		assert.NoError(t, err, tt.expression)
+		assert.InDelta(t, tt.expected, value, 1e-9, tt.expression)
+	}
+}
+
+func TestExpression_Evaluate_Error(t *testing.T) {
+	e, err := ParseExpression("a / b")
+	assert.NoError(t, err)
+
+	_, err = e.Evaluate(map[string]float64{"a": 1, "b": 0})
+	assert.EqualError(t, err, "division by zero")
+
+	_, err = e.Evaluate(map[string]float64{"a": 1})
+	assert.EqualError(t, err, "no value for b")
+}
+
+func TestParseExpression_Error(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"a +",
+		"a % b",
+		"a == b",
+		"f(a)",
+		"a.b",
+		"a[0]",
+		"\"text\"",
+		"'c'",
+		"!a",
+		"0x",
+	} {
+		_, err := ParseExpression(expression)
+		assert.Error(t, err, expression)
+	}
+}
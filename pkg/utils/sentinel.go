@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sentinel is a value a device reports when it has no real value for a
+// register, e.g. 0xFFFF for a disconnected channel.
+type Sentinel struct {
+	// NaN matches any floating point NaN.
+	NaN bool
+
+	// Value is compared with the decoded value.
+	Value float64
+
+	// Bits is compared with the raw register bits read as an unsigned integer.
+	// This lets 0x8000 match a signed 16-bit register. Only set for
+	// non-negative integer sentinels, see HasBits.
+	Bits    uint64
+	HasBits bool
+}
+
+// ParseSentinel parses a sentinel from device configuration. Numbers may be
+// given as YAML numbers or strings (decimal or 0x prefixed hex). The string
+// "nan" matches any floating point NaN.
+func ParseSentinel(s interface{}) (sentinel Sentinel, err error) {
+	switch v := s.(type) {
+	case int:
+		return integerSentinel(int64(v)), nil
+	case int64:
+		return integerSentinel(v), nil
+	case uint:
+		return unsignedSentinel(uint64(v)), nil
+	case uint64:
+		return unsignedSentinel(v), nil
+	case float64:
+		if math.IsNaN(v) {
+			return Sentinel{NaN: true}, nil
+		}
+		if v == math.Trunc(v) && v >= 0 && v < math.MaxUint64 {
+			return unsignedSentinel(uint64(v)), nil
+		}
+		return Sentinel{Value: v}, nil
+	case string:
+		str := strings.ToLower(strings.TrimSpace(v))
+		if str == "nan" {
+			return Sentinel{NaN: true}, nil
+		}
+		if u, err := strconv.ParseUint(str, 0, 64); err == nil {
+			return unsignedSentinel(u), nil
+		}
+		if i, err := strconv.ParseInt(str, 0, 64); err == nil {
+			return integerSentinel(i), nil
+		}
+		if f, err := strconv.ParseFloat(str, 64); err == nil {
+			return Sentinel{Value: f}, nil
+		}
+	}
+	return sentinel, fmt.Errorf("invalid not available value: %v", s)
+}
+
+// ParseSentinels parses a list of sentinels, see ParseSentinel.
+func ParseSentinels(list []interface{}) (sentinels []Sentinel, err error) {
+	for _, s := range list {
+		sentinel, err := ParseSentinel(s)
+		if err != nil {
+			return nil, err
+		}
+		sentinels = append(sentinels, sentinel)
+	}
+	return
+}
+
+// integerSentinel makes a sentinel from a signed integer.
+func integerSentinel(i int64) Sentinel {
+	if i >= 0 {
+		return unsignedSentinel(uint64(i))
+	}
+	return Sentinel{Value: float64(i)}
+}
+
+// unsignedSentinel makes a sentinel from an unsigned integer.
+func unsignedSentinel(u uint64) Sentinel {
+	return Sentinel{Value: float64(u), Bits: u, HasBits: true}
+}
+
+// Matches returns true if the decoded value or the raw register bytes match
+// the sentinel. Only numeric decoded values are compared.
+func (s Sentinel) Matches(value interface{}, raw []byte) bool {
+	if s.HasBits && len(raw) <= 8 {
+		var bits uint64
+		for i := 0; i < len(raw); i++ {
+			bits = bits<<8 | uint64(raw[i])
+		}
+		if len(raw) > 0 && bits == s.Bits {
+			return true
+		}
+	}
+
+	f, ok := ToFloat64(value)
+	if !ok {
+		return false
+	}
+	if s.NaN {
+		return math.IsNaN(f)
+	}
+	return f == s.Value
+}
+
+// IsNotAvailable returns true if the decoded value or raw register bytes match
+// any of the sentinels.
+func IsNotAvailable(value interface{}, raw []byte, sentinels []Sentinel) bool {
+	for _, s := range sentinels {
+		if s.Matches(value, raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToFloat64 converts a numeric decoded value to a float64. ok is false if the
+// value is not numeric.
+func ToFloat64(value interface{}) (f float64, ok bool) {
+	switch v := value.(type) {
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// FromFloat64 converts f to the same numeric type as like. Integer types
+// saturate at the limits of the type rather than overflow.
+func FromFloat64(f float64, like interface{}) interface{} {
+	switch like.(type) {
+	case uint8:
+		return uint8(saturate(f, 0, math.MaxUint8))
+	case uint16:
+		return uint16(saturate(f, 0, math.MaxUint16))
+	case uint32:
+		return uint32(saturate(f, 0, math.MaxUint32))
+	case uint64:
+		// float64(math.MaxUint64) rounds up past the limit.
+		if f >= math.MaxUint64 {
+			return uint64(math.MaxUint64)
+		}
+		return uint64(saturate(f, 0, f))
+	case int8:
+		return int8(saturate(f, math.MinInt8, math.MaxInt8))
+	case int16:
+		return int16(saturate(f, math.MinInt16, math.MaxInt16))
+	case int32:
+		return int32(saturate(f, math.MinInt32, math.MaxInt32))
+	case int64:
+		// float64(math.MaxInt64) rounds up past the limit.
+		if f >= math.MaxInt64 {
+			return int64(math.MaxInt64)
+		}
+		return int64(saturate(f, math.MinInt64, f))
+	case float32:
+		return float32(f)
+	}
+	return f
+}
+
+// saturate limits f to min and max.
+func saturate(f, min, max float64) float64 {
+	return math.Min(math.Max(f, min), max)
+}
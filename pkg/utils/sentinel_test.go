@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSentinel_Error(t *testing.T) {
+	for _, s := range []interface{}{"foo", "0xzz", true, nil, []int{1}} {
+		_, err := ParseSentinel(s)
+		assert.Error(t, err, "%v", s)
+	}
+}
+
+func TestIsNotAvailable(t *testing.T) {
+	var tests = []struct {
+		typeName  string
+		raw       []byte
+		sentinels []interface{}
+		expected  bool
+	}{
+		// Raw bits match a signed register.
+		{typeName: "s16", raw: []byte{0x80, 0x00}, sentinels: []interface{}{0x8000}, expected: true},
+		{typeName: "s16", raw: []byte{0x80, 0x00}, sentinels: []interface{}{"0x8000"}, expected: true},
+		{typeName: "s16", raw: []byte{0x80, 0x01}, sentinels: []interface{}{0x8000}, expected: false},
+		// Decoded value matches.
+		{typeName: "s16", raw: []byte{0xff, 0xff}, sentinels: []interface{}{-1}, expected: true},
+		{typeName: "u16", raw: []byte{0xff, 0xff}, sentinels: []interface{}{65535}, expected: true},
+		{typeName: "u32", raw: []byte{0x7f, 0xff, 0xff, 0xff}, sentinels: []interface{}{0x7fffffff}, expected: true},
+		{typeName: "f32", raw: []byte{0x3f, 0xc0, 0x00, 0x00}, sentinels: []interface{}{1.5}, expected: true},
+		{typeName: "f32", raw: []byte{0x3f, 0xc0, 0x00, 0x00}, sentinels: []interface{}{"-1.5"}, expected: false},
+		// NaN.
+		{typeName: "f32", raw: []byte{0x7f, 0xc0, 0x00, 0x00}, sentinels: []interface{}{"nan"}, expected: true},
+		{typeName: "f64", raw: []byte{0x7f, 0xf8, 0, 0, 0, 0, 0, 1}, sentinels: []interface{}{math.NaN()}, expected: true},
+		{typeName: "f32", raw: []byte{0x00, 0x00, 0x00, 0x00}, sentinels: []interface{}{"NaN"}, expected: false},
+		// Strings are only compared by raw bits.
+		{typeName: "t", raw: []byte{0x41, 0x42}, sentinels: []interface{}{"nan", -1}, expected: false},
+		// No sentinels.
+		{typeName: "u16", raw: []byte{0xff, 0xff}, sentinels: nil, expected: false},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%d", tt.typeName, i), func(t *testing.T) {
+			sentinels, err := ParseSentinels(tt.sentinels)
+			assert.NoError(t, err)
+			value, err := CastToType(tt.typeName, tt.raw)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, IsNotAvailable(value, tt.raw, sentinels))
+		})
+	}
+}
+
+// The per-type defaults parse and match the values they are meant for.
+func TestNotAvailableDefaults(t *testing.T) {
+	var tests = []struct {
+		typeName string
+		raw      []byte
+	}{
+		{typeName: "u16", raw: []byte{0xff, 0xff}},
+		{typeName: "s16", raw: []byte{0x80, 0x00}},
+		{typeName: "u32", raw: []byte{0xff, 0xff, 0xff, 0xff}},
+		{typeName: "s32", raw: []byte{0x80, 0x00, 0x00, 0x00}},
+		{typeName: "u64", raw: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{typeName: "s64", raw: []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{typeName: "f16", raw: []byte{0x7e, 0x00}},
+		{typeName: "f32", raw: []byte{0x7f, 0xc0, 0x00, 0x00}},
+		{typeName: "f64", raw: []byte{0x7f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{typeName: "cdabswapf32", raw: []byte{0x00, 0x00, 0x7f, 0xc0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typeName, func(t *testing.T) {
+			sentinels, err := ParseSentinels(LookupType(tt.typeName).NotAvailable)
+			assert.NoError(t, err)
+			value, err := CastToType(tt.typeName, tt.raw)
+			assert.NoError(t, err)
+			assert.True(t, IsNotAvailable(value, tt.raw, sentinels))
+
+			// Zero is a real value.
+			zero := make([]byte, len(tt.raw))
+			value, err = CastToType(tt.typeName, zero)
+			assert.NoError(t, err)
+			assert.False(t, IsNotAvailable(value, zero, sentinels))
+		})
+	}
+}
+
+// Integer conversions saturate at the limits of the type.
+func TestFromFloat64(t *testing.T) {
+	var tests = []struct {
+		f        float64
+		like     interface{}
+		expected interface{}
+	}{
+		{f: 12, like: uint16(0), expected: uint16(12)},
+		{f: 70000, like: uint16(0), expected: uint16(math.MaxUint16)},
+		{f: -1, like: uint16(0), expected: uint16(0)},
+		{f: -200, like: int8(0), expected: int8(math.MinInt8)},
+		{f: 3e9, like: int32(0), expected: int32(math.MaxInt32)},
+		{f: 1e20, like: uint64(0), expected: uint64(math.MaxUint64)},
+		{f: -1e20, like: int64(0), expected: int64(math.MinInt64)},
+		{f: 1e20, like: int64(0), expected: int64(math.MaxInt64)},
+		{f: 1.5, like: float32(0), expected: float32(1.5)},
+		{f: 1.5, like: float64(0), expected: float64(1.5)},
+	}
+
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			assert.Equal(t, tt.expected, FromFloat64(tt.f, tt.like))
+		})
+	}
+}
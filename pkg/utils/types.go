@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TypeDecoder describes a named register data type: how many registers it
+// occupies and how to convert between raw register bytes and values.
+type TypeDecoder struct {
+	// Name is the type name referenced by the device `type` field. Type names
+	// are case insensitive.
+	Name string
+
+	// Aliases are alternative names for the type.
+	Aliases []string
+
+	// Width is the number of registers the type occupies. Zero means the
+	// type does not have a fixed width (strings, coils).
+	Width uint16
+
+	// Decode converts raw register bytes to a reading value.
+	Decode func(value []byte) (interface{}, error)
+
+	// Encode converts a value to raw register bytes for a write. This is
+	// optional. Types without an encoder can not be written.
+	Encode func(value string) ([]byte, error)
+
+	// String is true for string types. Devices of a string type can set
+	// stringOptions, which are used to decode readings and encode typed
+	// writes.
+	String bool
+
+	// NotAvailable are the values this type commonly uses to mean that there
+	// is no value, in the form accepted by ParseSentinel. Devices opt in to
+	// these with notAvailableDefaults.
+	NotAvailable []interface{}
+}
+
+// typeRegistry holds all registered types keyed by lower case name and alias.
+var typeRegistry = make(map[string]*TypeDecoder)
+var typeRegistryMutex sync.RWMutex
+
+// RegisterType adds a type to the type registry so that it can be referenced
+// by device configuration. Types must be registered before the plugin loads
+// its devices, so plugins embedding this one should do it before MakePlugin.
+// It is an error to register a name or alias which is already registered.
+func RegisterType(t TypeDecoder) error {
+	if t.Name == "" {
+		return fmt.Errorf("type has no name")
+	}
+	if t.Decode == nil {
+		return fmt.Errorf("type %s has no decode function", t.Name)
+	}
+
+	names := append([]string{t.Name}, t.Aliases...)
+
+	typeRegistryMutex.Lock()
+	defer typeRegistryMutex.Unlock()
+	for _, name := range names {
+		if _, exists := typeRegistry[strings.ToLower(name)]; exists {
+			return fmt.Errorf("type %s is already registered", name)
+		}
+	}
+	for _, name := range names {
+		typeRegistry[strings.ToLower(name)] = &t
+	}
+	return nil
+}
+
+// LookupType gets the registered type for typeName, or nil if there is none.
+// Fixed-point types (see ParseFixedPointType) do not need to be registered.
+func LookupType(typeName string) *TypeDecoder {
+	typeRegistryMutex.RLock()
+	t := typeRegistry[strings.ToLower(typeName)]
+	typeRegistryMutex.RUnlock()
+	if t != nil {
+		return t
+	}
+
+	if signed, bits, fraction, ok := ParseFixedPointType(typeName); ok {
+		return &TypeDecoder{
+			Name:  typeName,
+			Width: uint16(bits / 16),
+			Decode: func(value []byte) (interface{}, error) {
+				if len(value) != int(bits/8) {
+					return nil, fmt.Errorf("%s must be %d bytes, is %d", typeName, bits/8, len(value))
+				}
+				return Bytes(value).FixedPoint(signed, fraction)
+			},
+		}
+	}
+	return nil
+}
+
+// mustRegisterType registers a built-in type, panicking on a programming error.
+func mustRegisterType(t TypeDecoder) {
+	if err := RegisterType(t); err != nil {
+		panic(err)
+	}
+}
+
+// Register the built-in types.
+func init() {
+	// unsigned integers
+	mustRegisterType(TypeDecoder{
+		Name: "u8", Aliases: []string{"uint8"}, Width: 1,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Uint8(), nil },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "u16", Aliases: []string{"uint16"}, Width: 1,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Uint16(), nil },
+		Encode:       func(v string) ([]byte, error) { return EncodeUint(v, 2) },
+		NotAvailable: []interface{}{uint64(0xffff)},
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "u24", Aliases: []string{"uint24"}, Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Uint24() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "u32", Aliases: []string{"uint32"}, Width: 2,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Uint32(), nil },
+		Encode:       func(v string) ([]byte, error) { return EncodeUint(v, 4) },
+		NotAvailable: []interface{}{uint64(0xffffffff)},
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "u48", Aliases: []string{"uint48"}, Width: 3,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Uint48() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "u64", Aliases: []string{"uint64"}, Width: 4,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Uint64(), nil },
+		Encode:       func(v string) ([]byte, error) { return EncodeUint(v, 8) },
+		NotAvailable: []interface{}{uint64(0xffffffffffffffff)},
+	})
+
+	// signed integers
+	mustRegisterType(TypeDecoder{
+		Name: "s8", Aliases: []string{"int8"}, Width: 1,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Int8(), nil },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s16", Aliases: []string{"int16"}, Width: 1,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Int16() },
+		Encode:       func(v string) ([]byte, error) { return EncodeInt(v, 2) },
+		NotAvailable: []interface{}{uint64(0x8000)},
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s24", Aliases: []string{"int24"}, Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Int24() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s32", Aliases: []string{"int32"}, Width: 2,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Int32() },
+		Encode:       func(v string) ([]byte, error) { return EncodeInt(v, 4) },
+		NotAvailable: []interface{}{uint64(0x80000000)},
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s48", Aliases: []string{"int48"}, Width: 3,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Int48() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s64", Aliases: []string{"int64"}, Width: 4,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Int64() },
+		Encode:       func(v string) ([]byte, error) { return EncodeInt(v, 8) },
+		NotAvailable: []interface{}{uint64(0x8000000000000000)},
+	})
+
+	// sign-magnitude integers
+	mustRegisterType(TypeDecoder{
+		Name: "sm16", Width: 1,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).SignMagnitude16() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "sm32", Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).SignMagnitude32() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "sm64", Width: 4,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).SignMagnitude64() },
+	})
+
+	// binary-coded decimal
+	mustRegisterType(TypeDecoder{
+		Name: "bcd16", Width: 1,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Bcd16() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "bcd32", Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Bcd32() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "sbcd16", Width: 1,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).SignedBcd16() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "sbcd32", Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).SignedBcd32() },
+	})
+
+	// modulo-10000 integers
+	mustRegisterType(TypeDecoder{
+		Name: "u32m10", Aliases: []string{"uint32m10"}, Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Uint32M10() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "u48m10", Aliases: []string{"uint48m10"}, Width: 3,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Uint48M10() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "u64m10", Aliases: []string{"uint64m10"}, Width: 4,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Uint64M10() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s32m10", Aliases: []string{"int32m10"}, Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Int32M10() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s48m10", Aliases: []string{"int48m10"}, Width: 3,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Int48M10() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "s64m10", Aliases: []string{"int64m10"}, Width: 4,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Int64M10() },
+	})
+
+	// floating point numbers
+	mustRegisterType(TypeDecoder{
+		Name: "f16", Aliases: []string{"float16"}, Width: 1,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Float16() },
+		NotAvailable: []interface{}{"nan"},
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "f32", Aliases: []string{"float32"}, Width: 2,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Float32(), nil },
+		Encode:       EncodeFloat32,
+		NotAvailable: []interface{}{"nan"},
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "f64", Aliases: []string{"float64"}, Width: 4,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).Float64(), nil },
+		Encode:       EncodeFloat64,
+		NotAvailable: []interface{}{"nan"},
+	})
+	mustRegisterType(TypeDecoder{
+		// Swap raw bytes from ABCD to CDAB, then convert to f32.
+		Name: "cdabswapf32", Width: 2,
+		Decode:       func(v []byte) (interface{}, error) { return Bytes(v).SwapCdabFloat32(), nil },
+		Encode:       EncodeCdabFloat32,
+		NotAvailable: []interface{}{"nan"},
+	})
+
+	// booleans, typically coils
+	mustRegisterType(TypeDecoder{
+		Name: "b", Aliases: []string{"bool", "boolean"},
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Bool(), nil },
+	})
+
+	// utf-8 strings
+	// s is taken (signed), t is like the old _T C macro.
+	// The numbers here are two byte words. A t10 string is 20 bytes.
+	// Here we are ignoring the length because data length is handled up the line.
+	mustRegisterType(TypeDecoder{
+		Name: "t", Aliases: []string{"t4", "t8", "t10", "t12", "t16", "t20", "string", "utf8"},
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Utf8(), nil },
+		Encode: func(v string) ([]byte, error) { return []byte(v), nil },
+		String: true,
+	})
+
+	// mac addresses
+	mustRegisterType(TypeDecoder{
+		// 6 bytes containing a mac address.
+		Name: "macaddress", Width: 3,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).MacAddress() },
+	})
+	mustRegisterType(TypeDecoder{
+		// 12 bytes from 6 uints containing a mac address.
+		Name: "macaddresswide", Width: 6,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).MacAddressWide() },
+	})
+
+	// dates, times and durations
+	mustRegisterType(TypeDecoder{
+		Name: "unix32", Aliases: []string{"epoch32"}, Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).UnixTime32() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "unix64", Aliases: []string{"epoch64"}, Width: 4,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).UnixTime64() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "y2k32", Aliases: []string{"epoch2000"}, Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Y2KTime32() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "datetime", Width: 6,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).DateTime() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "datetimepacked", Width: 3,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).PackedDateTime() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "duration32", Width: 2,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Duration32() },
+	})
+	mustRegisterType(TypeDecoder{
+		Name: "duration64", Width: 4,
+		Decode: func(v []byte) (interface{}, error) { return Bytes(v).Duration64() },
+	})
+
+	// Raw bytes ("b16", "bytes") are deprecated and deliberately not registered.
+	// GRPC may not handle them in the way we might think.
+	// They're not especially useful to the synse client either.
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unregisterType removes a type registered by a test.
+func unregisterType(names ...string) {
+	typeRegistryMutex.Lock()
+	defer typeRegistryMutex.Unlock()
+	for _, name := range names {
+		delete(typeRegistry, name)
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	defer unregisterType("test-type", "test-alias")
+
+	err := RegisterType(TypeDecoder{
+		Name:    "Test-Type",
+		Aliases: []string{"test-alias"},
+		Width:   1,
+		Decode: func(v []byte) (interface{}, error) {
+			return fmt.Sprintf("%x", v), nil
+		},
+	})
+	assert.NoError(t, err)
+
+	actual, err := CastToType("test-type", []byte{0xbe, 0xef})
+	assert.NoError(t, err)
+	assert.Equal(t, "beef", actual)
+
+	actual, err = CastToType("TEST-ALIAS", []byte{0xbe, 0xef})
+	assert.NoError(t, err)
+	assert.Equal(t, "beef", actual)
+
+	registered := LookupType("test-type")
+	assert.NotNil(t, registered)
+	assert.Equal(t, uint16(1), registered.Width)
+	assert.Nil(t, registered.Encode)
+}
+
+func TestIsStringType(t *testing.T) {
+	defer unregisterType("test-string")
+
+	assert.True(t, IsStringType("t10"))
+	assert.True(t, IsStringType("UTF8"))
+	assert.False(t, IsStringType("u16"))
+	assert.False(t, IsStringType("foo"))
+
+	// Registered string types are string types.
+	assert.False(t, IsStringType("test-string"))
+	err := RegisterType(TypeDecoder{
+		Name:   "test-string",
+		Decode: func(v []byte) (interface{}, error) { return string(v), nil },
+		String: true,
+	})
+	assert.NoError(t, err)
+	assert.True(t, IsStringType("test-string"))
+}
+
+func TestRegisterType_Error(t *testing.T) {
+	decode := func(v []byte) (interface{}, error) { return nil, nil }
+
+	// No name.
+	assert.Error(t, RegisterType(TypeDecoder{Decode: decode}))
+
+	// No decode function.
+	assert.Error(t, RegisterType(TypeDecoder{Name: "test-no-decode"}))
+	assert.Nil(t, LookupType("test-no-decode"))
+
+	// Name collides with a built-in type.
+	assert.Error(t, RegisterType(TypeDecoder{Name: "F32", Decode: decode}))
+
+	// Alias collides with a built-in type. Nothing is registered.
+	assert.Error(t, RegisterType(TypeDecoder{Name: "test-collide", Aliases: []string{"uint16"}, Decode: decode}))
+	assert.Nil(t, LookupType("test-collide"))
+}
+
+func TestLookupType(t *testing.T) {
+	var tests = []struct {
+		typeName string
+		width    uint16
+	}{
+		{typeName: "u16", width: 1},
+		{typeName: "Float32", width: 2},
+		{typeName: "s64", width: 4},
+		{typeName: "macAddressWide", width: 6},
+		{typeName: "t10", width: 0},
+		{typeName: "q7.8", width: 1},
+		{typeName: "uq32.32", width: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typeName, func(t *testing.T) {
+			actual := LookupType(tt.typeName)
+			assert.NotNil(t, actual)
+			assert.Equal(t, tt.width, actual.Width)
+		})
+	}
+
+	assert.Nil(t, LookupType("foo"))
+	assert.Nil(t, LookupType("bytes"))
+}
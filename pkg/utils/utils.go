@@ -5,7 +5,11 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // Bytes represents a slice of bytes and provides conversion functions
@@ -26,6 +30,34 @@ func (b Bytes) Float64() float64 {
 	return math.Float64frombits(binary.BigEndian.Uint64(b))
 }
 
+// Float16 converts a two byte IEEE-754 half-precision value to a float32.
+func (b Bytes) Float16() (out float32, err error) {
+	if len(b) != 2 {
+		err = fmt.Errorf("float16 must be two bytes, is %d", len(b))
+		return
+	}
+	h := binary.BigEndian.Uint16(b)
+	sign := uint32(h>>15) << 31
+	exponent := uint32(h>>10) & 0x1f
+	fraction := uint32(h) & 0x3ff
+
+	switch exponent {
+	case 0:
+		// Zero or subnormal. There is no implicit leading one.
+		out = float32(math.Ldexp(float64(fraction), -24))
+		if sign != 0 {
+			out = -out
+		}
+	case 0x1f:
+		// Infinity or NaN.
+		out = math.Float32frombits(sign | 0x7f800000 | fraction<<13)
+	default:
+		// Normal number. Rebias the exponent from 15 to 127.
+		out = math.Float32frombits(sign | (exponent+112)<<23 | fraction<<13)
+	}
+	return
+}
+
 // Uint8 converts the byte slice to a uint8.
 func (b Bytes) Uint8() uint8 {
 	return b[0]
@@ -46,6 +78,32 @@ func (b Bytes) Uint64() uint64 {
 	return binary.BigEndian.Uint64(b)
 }
 
+// Uint24 converts the byte slice to a uint32 holding an unsigned 24-bit integer.
+// The value may be three bytes or two registers, in which case the most
+// significant byte is padding and is ignored.
+func (b Bytes) Uint24() (out uint32, err error) {
+	if len(b) != 3 && len(b) != 4 {
+		err = fmt.Errorf("24-bit integer must be three or four bytes, is %d", len(b))
+		return
+	}
+	v := b[len(b)-3:]
+	out = uint32(v[0])<<16 | uint32(v[1])<<8 | uint32(v[2])
+	return
+}
+
+// Uint48 converts six bytes (three registers) to a uint64 holding an unsigned
+// 48-bit integer.
+func (b Bytes) Uint48() (out uint64, err error) {
+	if len(b) != 6 {
+		err = fmt.Errorf("48-bit integer must be six bytes, is %d", len(b))
+		return
+	}
+	for i := 0; i < len(b); i++ {
+		out = out<<8 | uint64(b[i])
+	}
+	return
+}
+
 // Int8 converts the byte slice into an int8.
 func (b Bytes) Int8() int8 {
 	return int8(b[0])
@@ -72,6 +130,86 @@ func (b Bytes) Int64() (out int64, err error) {
 	return
 }
 
+// Int24 converts the byte slice to an int32 holding a signed 24-bit integer.
+// Padding is handled the same as Uint24.
+func (b Bytes) Int24() (out int32, err error) {
+	u, err := b.Uint24()
+	if err != nil {
+		return
+	}
+	// Shift the sign bit to the top and back down to sign extend.
+	out = int32(u<<8) >> 8
+	return
+}
+
+// Int48 converts six bytes (three registers) to an int64 holding a signed
+// 48-bit integer.
+func (b Bytes) Int48() (out int64, err error) {
+	u, err := b.Uint48()
+	if err != nil {
+		return
+	}
+	out = int64(u<<16) >> 16
+	return
+}
+
+// SignMagnitude16 converts a two byte sign-magnitude integer to an int16.
+func (b Bytes) SignMagnitude16() (out int16, err error) {
+	value, err := b.signMagnitude(2)
+	return int16(value), err
+}
+
+// SignMagnitude32 converts a four byte sign-magnitude integer to an int32.
+func (b Bytes) SignMagnitude32() (out int32, err error) {
+	value, err := b.signMagnitude(4)
+	return int32(value), err
+}
+
+// SignMagnitude64 converts an eight byte sign-magnitude integer to an int64.
+func (b Bytes) SignMagnitude64() (out int64, err error) {
+	return b.signMagnitude(8)
+}
+
+// signMagnitude decodes the byte slice as a sign-magnitude integer. The most
+// significant bit is the sign and the remaining bits are the magnitude.
+func (b Bytes) signMagnitude(size int) (out int64, err error) {
+	if len(b) != size {
+		err = fmt.Errorf("%d-bit sign-magnitude integer must be %d bytes, is %d", 8*size, size, len(b))
+		return
+	}
+	var u uint64
+	for i := 0; i < len(b); i++ {
+		u = u<<8 | uint64(b[i])
+	}
+	signBit := uint64(1) << uint(8*size-1)
+	out = int64(u &^ signBit)
+	if u&signBit != 0 {
+		out = -out
+	}
+	return
+}
+
+// FixedPoint converts the byte slice to a float64 by treating it as a two's
+// complement (signed) or unsigned integer scaled by 2^-fractionBits.
+func (b Bytes) FixedPoint(signed bool, fractionBits uint) (out float64, err error) {
+	if len(b) != 2 && len(b) != 4 && len(b) != 8 {
+		err = fmt.Errorf("fixed-point value must be 2, 4 or 8 bytes, is %d", len(b))
+		return
+	}
+	var u uint64
+	for i := 0; i < len(b); i++ {
+		u = u<<8 | uint64(b[i])
+	}
+	shift := uint(64 - 8*len(b))
+	if signed {
+		out = float64(int64(u<<shift) >> shift)
+	} else {
+		out = float64(u)
+	}
+	out = math.Ldexp(out, -int(fractionBits))
+	return
+}
+
 // Bool converts the byte slice to a bool.
 func (b Bytes) Bool() bool {
 	if b == nil || len(b) == 0 {
@@ -118,121 +256,434 @@ func (b Bytes) SwapCdabFloat32() (out float32) {
 	return math.Float32frombits(x)
 }
 
-// CpmModelNumber is specific to busway CPM data at register 0x12C.
-// The documentation for this is "Model Number" and tech support is not there.
-// We see hex data coming in like 0x00000000000000004634303039303335.
-// In this case, trim the leading 0x00s and convert to string.
-// Data above converts to F4009036
-func (b Bytes) CpmModelNumber() (out string) {
-	i := 0
-	for ; i < len(b); i++ {
-		if b[i] != 0x00 {
-			break
-		}
+// Bcd16 converts a two byte packed binary-coded decimal value to a uint16.
+// Each nibble holds one decimal digit, so the range is 0 to 9999.
+func (b Bytes) Bcd16() (out uint16, err error) {
+	if len(b) != 2 {
+		err = fmt.Errorf("bcd16 must be two bytes, is %d", len(b))
+		return
+	}
+	value, err := b.bcd()
+	return uint16(value), err
+}
+
+// Bcd32 converts a four byte packed binary-coded decimal value to a uint32.
+// Each nibble holds one decimal digit, so the range is 0 to 99999999.
+func (b Bytes) Bcd32() (out uint32, err error) {
+	if len(b) != 4 {
+		err = fmt.Errorf("bcd32 must be four bytes, is %d", len(b))
+		return
+	}
+	value, err := b.bcd()
+	return uint32(value), err
+}
+
+// SignedBcd16 converts a two byte packed binary-coded decimal value with a
+// leading sign nibble to an int16. The sign nibble is 0x0 for positive values
+// and 0xf for negative values, leaving three digits (-999 to 999).
+func (b Bytes) SignedBcd16() (out int16, err error) {
+	if len(b) != 2 {
+		err = fmt.Errorf("sbcd16 must be two bytes, is %d", len(b))
+		return
+	}
+	value, err := b.signedBcd()
+	return int16(value), err
+}
+
+// SignedBcd32 converts a four byte packed binary-coded decimal value with a
+// leading sign nibble to an int32. The sign nibble is 0x0 for positive values
+// and 0xf for negative values, leaving seven digits (-9999999 to 9999999).
+func (b Bytes) SignedBcd32() (out int32, err error) {
+	if len(b) != 4 {
+		err = fmt.Errorf("sbcd32 must be four bytes, is %d", len(b))
+		return
 	}
-	return string(b[i:])
+	value, err := b.signedBcd()
+	return int32(value), err
 }
 
-// CpmSerialNumber is specific to busway CPM data at register 0x134.
-// The documentation for this is "Serial Number" and tech support is not there.
-// We see hex data coming in like 0x2d53312d383838003030323937303800.
-// In this case, convert 0x00 to ASCII space and convert to string.
-// Trim leading and trailing whitespace.
-// Data above converts to -S1-21- 0030177
-func (b Bytes) CpmSerialNumber() (out string) {
+// bcd decodes the byte slice as packed binary-coded decimal, two digits per
+// byte, most significant digit first. Any nibble outside 0-9 is an error so
+// that corrupt data does not turn into a plausible looking number.
+func (b Bytes) bcd() (out uint64, err error) {
 	for i := 0; i < len(b); i++ {
-		if b[i] == 0x00 {
-			b[i] = 0x20
+		high := b[i] >> 4
+		low := b[i] & 0x0f
+		if high > 9 || low > 9 {
+			return 0, fmt.Errorf("invalid bcd digit in byte 0x%02x at offset %d", b[i], i)
 		}
+		out = out*100 + uint64(high)*10 + uint64(low)
 	}
-	return strings.TrimSpace(string(b))
+	return
 }
 
-// CastToType takes a typeName, which represents a well-known type, and
-// a byte slice and will attempt to cast the byte slice to the named type.
-func CastToType(typeName string, value []byte) (interface{}, error) {
+// signedBcd decodes the byte slice as packed binary-coded decimal where the
+// most significant nibble is the sign.
+func (b Bytes) signedBcd() (out int64, err error) {
+	if len(b) == 0 {
+		return 0, fmt.Errorf("no bcd data")
+	}
+	sign := b[0] >> 4
+	if sign != 0x0 && sign != 0xf {
+		return 0, fmt.Errorf("invalid bcd sign nibble 0x%x", sign)
+	}
 
-	switch strings.ToLower(typeName) {
+	digits := make([]byte, len(b))
+	copy(digits, b)
+	digits[0] &= 0x0f
+	magnitude, err := Bytes(digits).bcd()
+	if err != nil {
+		return 0, err
+	}
 
-	case "u8", "uint8":
-		// unsigned 8-bit integer
-		return Bytes(value).Uint8(), nil
+	out = int64(magnitude)
+	if sign == 0xf {
+		out = -out
+	}
+	return
+}
 
-	case "u16", "uint16":
-		// unsigned 16-bit integer
-		return Bytes(value).Uint16(), nil
+// Uint32M10 converts two modulo-10000 registers to a uint32.
+// See mod10k for the register layout.
+func (b Bytes) Uint32M10() (out uint32, err error) {
+	value, err := b.mod10k(2)
+	return uint32(value), err
+}
 
-	case "u32", "uint32":
-		// unsigned 32-bit integer
-		return Bytes(value).Uint32(), nil
+// Uint48M10 converts three modulo-10000 registers to a uint64.
+// See mod10k for the register layout.
+func (b Bytes) Uint48M10() (out uint64, err error) {
+	return b.mod10k(3)
+}
 
-	case "u64", "uint64":
-		// unsigned 64-bit integer
-		return Bytes(value).Uint64(), nil
+// Uint64M10 converts four modulo-10000 registers to a uint64.
+// See mod10k for the register layout.
+func (b Bytes) Uint64M10() (out uint64, err error) {
+	return b.mod10k(4)
+}
 
-	case "s8", "int8":
-		// signed 8-bit integer
-		return Bytes(value).Int8(), nil
+// Int32M10 converts two signed modulo-10000 registers to an int32.
+// See signedMod10k for the register layout.
+func (b Bytes) Int32M10() (out int32, err error) {
+	value, err := b.signedMod10k(2)
+	return int32(value), err
+}
 
-	case "s16", "int16":
-		// signed 16-bit integer
-		return Bytes(value).Int16()
+// Int48M10 converts three signed modulo-10000 registers to an int64.
+// See signedMod10k for the register layout.
+func (b Bytes) Int48M10() (out int64, err error) {
+	return b.signedMod10k(3)
+}
 
-	case "s32", "int32":
-		// signed 32-bit integer
-		return Bytes(value).Int32()
+// Int64M10 converts four signed modulo-10000 registers to an int64.
+// See signedMod10k for the register layout.
+func (b Bytes) Int64M10() (out int64, err error) {
+	return b.signedMod10k(4)
+}
 
-	case "s64", "int64":
-		// signed 64-bit integer
-		return Bytes(value).Int64()
+// mod10k decodes the byte slice as the Schneider Electric modulo-10000 format
+// (UINT32M10 / INT64M10 and friends in PowerLogic documentation). Each register
+// holds 0 to 9999 and the first register is the least significant, so the value
+// is r0 + r1*10^4 + r2*10^8 + r3*10^12.
+func (b Bytes) mod10k(registers int) (out uint64, err error) {
+	if len(b) != 2*registers {
+		return 0, fmt.Errorf("modulo 10000 value must be %d bytes, is %d", 2*registers, len(b))
+	}
+	for i := registers - 1; i >= 0; i-- {
+		r := binary.BigEndian.Uint16(b[2*i:])
+		if r > 9999 {
+			return 0, fmt.Errorf("modulo 10000 register %d out of range: %d", i, r)
+		}
+		out = out*10000 + uint64(r)
+	}
+	return
+}
 
-	case "f32", "float32":
-		// 32-bit floating point number
-		return Bytes(value).Float32(), nil
+// signedMod10k decodes the byte slice as the signed modulo-10000 format. Each
+// register is a signed 16-bit integer from -9999 to 9999, the first register is
+// the least significant, and all non-zero registers must carry the same sign.
+func (b Bytes) signedMod10k(registers int) (out int64, err error) {
+	if len(b) != 2*registers {
+		return 0, fmt.Errorf("modulo 10000 value must be %d bytes, is %d", 2*registers, len(b))
+	}
+	negative, positive := false, false
+	for i := registers - 1; i >= 0; i-- {
+		r := int16(binary.BigEndian.Uint16(b[2*i:]))
+		if r > 9999 || r < -9999 {
+			return 0, fmt.Errorf("modulo 10000 register %d out of range: %d", i, r)
+		}
+		if r < 0 {
+			negative = true
+		} else if r > 0 {
+			positive = true
+		}
+		out = out*10000 + int64(r)
+	}
+	if negative && positive {
+		return 0, fmt.Errorf("modulo 10000 registers have mixed signs: %x", []byte(b))
+	}
+	return
+}
 
-	case "f64", "float64":
-		// 64-bit floating point number
-		return Bytes(value).Float64(), nil
+// y2kEpoch is the epoch for clocks which count seconds since 2000-01-01.
+var y2kEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
 
-	case "b", "bool", "boolean":
-		// bool
-		return Bytes(value).Bool(), nil
+// UnixTime32 converts four bytes of seconds since the Unix epoch to an RFC3339 timestamp.
+func (b Bytes) UnixTime32() (out string, err error) {
+	return b.secondsSince(time.Unix(0, 0).UTC(), 4)
+}
 
-	case "t", "t4", "t8", "t10", "t12", "t16", "t20", "string", "utf8":
-		// utf-8 string
-		// s is taken (signed), t is like the old _T C macro.
-		// The numbers here are two byte words. A t10 string is 20 bytes.
-		// Here we are ignoring the length because data length is handled up the line.
-		return Bytes(value).Utf8(), nil
+// UnixTime64 converts eight bytes of seconds since the Unix epoch to an RFC3339 timestamp.
+func (b Bytes) UnixTime64() (out string, err error) {
+	return b.secondsSince(time.Unix(0, 0).UTC(), 8)
+}
+
+// Y2KTime32 converts four bytes of seconds since 2000-01-01T00:00:00Z to an
+// RFC3339 timestamp.
+func (b Bytes) Y2KTime32() (out string, err error) {
+	return b.secondsSince(y2kEpoch, 4)
+}
+
+// Duration32 converts four bytes of seconds to a duration string such as 26h3m4s.
+func (b Bytes) Duration32() (out string, err error) {
+	return b.duration(4)
+}
+
+// Duration64 converts eight bytes of seconds to a duration string such as 26h3m4s.
+func (b Bytes) Duration64() (out string, err error) {
+	return b.duration(8)
+}
+
+// DateTime converts six registers holding year, month, day, hour, minute and
+// second (in that order) to an RFC3339 timestamp. The time is taken to be UTC.
+func (b Bytes) DateTime() (out string, err error) {
+	if len(b) != 12 {
+		err = fmt.Errorf("datetime must be 12 bytes, is %d", len(b))
+		return
+	}
+	var fields [6]int
+	for i := 0; i < len(fields); i++ {
+		fields[i] = int(binary.BigEndian.Uint16(b[2*i:]))
+	}
+	return dateTime(fields)
+}
+
+// PackedDateTime converts three registers holding one byte each of year since
+// 2000, month, day, hour, minute and second (in that order) to an RFC3339
+// timestamp. The time is taken to be UTC.
+func (b Bytes) PackedDateTime() (out string, err error) {
+	if len(b) != 6 {
+		err = fmt.Errorf("packed datetime must be six bytes, is %d", len(b))
+		return
+	}
+	var fields [6]int
+	for i := 0; i < len(fields); i++ {
+		fields[i] = int(b[i])
+	}
+	fields[0] += 2000
+	return dateTime(fields)
+}
+
+// secondsSince converts a size byte unsigned count of seconds since epoch to an
+// RFC3339 timestamp.
+func (b Bytes) secondsSince(epoch time.Time, size int) (out string, err error) {
+	seconds, err := b.seconds(size)
+	if err != nil {
+		return
+	}
+	return epoch.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339), nil
+}
+
+// duration converts a size byte unsigned count of seconds to a duration string.
+func (b Bytes) duration(size int) (out string, err error) {
+	seconds, err := b.seconds(size)
+	if err != nil {
+		return
+	}
+	return (time.Duration(seconds) * time.Second).String(), nil
+}
+
+// seconds converts a size byte unsigned count of seconds to an int64, making
+// sure that it fits in a time.Duration.
+func (b Bytes) seconds(size int) (out int64, err error) {
+	if len(b) != size {
+		err = fmt.Errorf("%d-bit seconds must be %d bytes, is %d", 8*size, size, len(b))
+		return
+	}
+	var u uint64
+	for i := 0; i < len(b); i++ {
+		u = u<<8 | uint64(b[i])
+	}
+	if u > uint64(math.MaxInt64/int64(time.Second)) {
+		err = fmt.Errorf("seconds out of range: %d", u)
+		return
+	}
+	return int64(u), nil
+}
+
+// dateTime validates year, month, day, hour, minute and second fields and
+// formats them as an RFC3339 timestamp.
+func dateTime(fields [6]int) (out string, err error) {
+	t := time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, time.UTC)
+	// time.Date normalizes out of range fields (e.g. month 13), so a round
+	// trip tells us whether the fields were valid.
+	if t.Year() != fields[0] || int(t.Month()) != fields[1] || t.Day() != fields[2] ||
+		t.Hour() != fields[3] || t.Minute() != fields[4] || t.Second() != fields[5] {
+		err = fmt.Errorf("invalid datetime fields: %v", fields)
+		return
+	}
+	return t.Format(time.RFC3339), nil
+}
 
-	case "b16", "bytes":
-		// Raw bytes are deprecated. GRPC may not handle them in the way we might think.
-		// They're not especially useful to the synse client either.
+// CastToType takes a typeName, which represents a well-known type, and
+// a byte slice and will attempt to cast the byte slice to the named type.
+// The type is looked up in the type registry, see RegisterType.
+func CastToType(typeName string, value []byte) (interface{}, error) {
+	t := LookupType(typeName)
+	if t == nil {
 		return nil, fmt.Errorf("unsupported output data type: %s", typeName)
+	}
+	return t.Decode(value)
+}
+
+// ParseFixedPointType parses a Qm.n fixed-point type name. Signed types are
+// named qM.N with M integer bits, N fraction bits and a sign bit. Unsigned types
+// are named uqM.N. The integer part may be omitted (q15 is q0.15). The total
+// number of bits must be 16, 32 or 64. ok is false if typeName is not a valid
+// fixed-point type name.
+func ParseFixedPointType(typeName string) (signed bool, bits uint, fraction uint, ok bool) {
+	name := strings.ToLower(typeName)
+	switch {
+	case strings.HasPrefix(name, "uq"):
+		name = name[2:]
+	case strings.HasPrefix(name, "q"):
+		signed = true
+		name = name[1:]
+	default:
+		return
+	}
+
+	integerPart, fractionPart := "0", name
+	if i := strings.Index(name, "."); i >= 0 {
+		integerPart, fractionPart = name[:i], name[i+1:]
+	}
+	m, err := strconv.ParseUint(integerPart, 10, 8)
+	if err != nil {
+		return
+	}
+	n, err := strconv.ParseUint(fractionPart, 10, 8)
+	if err != nil {
+		return
+	}
+
+	bits = uint(m + n)
+	if signed {
+		bits++
+	}
+	if bits != 16 && bits != 32 && bits != 64 {
+		return
+	}
+	return signed, bits, uint(n), true
+}
+
+// IsStringType returns true if typeName is a registered string type.
+func IsStringType(typeName string) bool {
+	t := LookupType(typeName)
+	return t != nil && t.String
+}
 
-	case "macaddress":
-		// 6 bytes containing a mac address.
-		return Bytes(value).MacAddress()
+// StringOptions are the options for decoding string typed register data.
+type StringOptions struct {
+	// ByteSwap swaps the two bytes of each register.
+	ByteSwap bool
 
-	case "macaddresswide":
-		// 12 bytes from 6 uints containing a mac address.
-		return Bytes(value).MacAddressWide()
+	// Trim is "nul", "space", "all" or empty for no trimming.
+	Trim string
 
-	case "cdabswapf32":
-		// Swap raw bytes from ABCD to DCBA, then convert to f32.
-		return Bytes(value).SwapCdabFloat32(), nil
+	// ReplaceNul, when set, replaces each NUL remaining after trimming instead
+	// of ending the string at the first NUL.
+	ReplaceNul *string
 
-	case "cpmmodelnumber":
-		// Busway CPM Model Number.
-		return Bytes(value).CpmModelNumber(), nil
+	// Encoding is "utf8" (the default), "ascii", "latin1", "utf16" or "utf16le".
+	Encoding string
+}
+
+// DecodeString decodes string register data using the given options. Register
+// byte swapping is done first, then the character encoding is applied, then the
+// string is trimmed, and finally any remaining NULs are either replaced or end
+// the string.
+func DecodeString(value []byte, opts *StringOptions) (out string, err error) {
+	if opts == nil {
+		return Bytes(value).Utf8(), nil
+	}
+
+	b := make([]byte, len(value))
+	copy(b, value)
+	if opts.ByteSwap {
+		if len(b)%2 != 0 {
+			return "", fmt.Errorf("byte swap requires an even number of bytes, have %d", len(b))
+		}
+		for i := 0; i < len(b); i += 2 {
+			b[i], b[i+1] = b[i+1], b[i]
+		}
+	}
 
-	case "cpmserialnumber":
-		// Busway CPM Serial Number.
-		return Bytes(value).CpmSerialNumber(), nil
+	switch strings.ToLower(opts.Encoding) {
+	case "", "utf8", "utf-8":
+		out = string(b)
+	case "ascii":
+		runes := make([]rune, len(b))
+		for i := 0; i < len(b); i++ {
+			runes[i] = rune(b[i])
+			if b[i] > 0x7f {
+				runes[i] = utf8.RuneError
+			}
+		}
+		out = string(runes)
+	case "latin1", "iso-8859-1":
+		runes := make([]rune, len(b))
+		for i := 0; i < len(b); i++ {
+			runes[i] = rune(b[i])
+		}
+		out = string(runes)
+	case "utf16", "utf-16", "utf16be", "utf16le":
+		if len(b)%2 != 0 {
+			return "", fmt.Errorf("utf16 requires an even number of bytes, have %d", len(b))
+		}
+		var order binary.ByteOrder = binary.BigEndian
+		if strings.ToLower(opts.Encoding) == "utf16le" {
+			order = binary.LittleEndian
+		}
+		units := make([]uint16, len(b)/2)
+		for i := 0; i < len(units); i++ {
+			units[i] = order.Uint16(b[2*i:])
+		}
+		out = string(utf16.Decode(units))
+	default:
+		return "", fmt.Errorf("unsupported string encoding: %s", opts.Encoding)
+	}
 
+	switch strings.ToLower(opts.Trim) {
+	case "":
+	case "nul":
+		out = strings.Trim(out, "\x00")
+	case "space":
+		out = strings.TrimSpace(out)
+	case "all":
+		out = strings.TrimFunc(out, func(r rune) bool {
+			return r == 0 || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+		})
 	default:
-		return nil, fmt.Errorf("unsupported output data type: %s", typeName)
+		return "", fmt.Errorf("unsupported string trim: %s", opts.Trim)
+	}
+
+	if opts.ReplaceNul != nil {
+		return strings.ReplaceAll(out, "\x00", *opts.ReplaceNul), nil
+	}
+	if i := strings.IndexByte(out, 0); i >= 0 {
+		out = out[:i]
 	}
+	return out, nil
 }
 
 // clen returns the index of the first NULL byte in n or len(n) if n contains no NULL byte.
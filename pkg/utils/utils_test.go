@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -319,6 +320,242 @@ func TestCastToType_Ok(t *testing.T) {
 			expectedLength: len("4.0.6"),
 		},
 
+		// binary-coded decimal
+		{
+			typeName: "bcd16",
+			value:    []byte{0x12, 0x34},
+			expected: uint16(1234),
+		},
+		{
+			typeName: "bcd16",
+			value:    []byte{0x99, 0x99},
+			expected: uint16(9999),
+		},
+		{
+			typeName: "bcd32",
+			value:    []byte{0x12, 0x34, 0x56, 0x78},
+			expected: uint32(12345678),
+		},
+		{
+			typeName: "bcd32",
+			value:    []byte{0x00, 0x00, 0x00, 0x00},
+			expected: uint32(0),
+		},
+		{
+			typeName: "sbcd16",
+			value:    []byte{0x01, 0x23},
+			expected: int16(123),
+		},
+		{
+			typeName: "sbcd16",
+			value:    []byte{0xf1, 0x23},
+			expected: int16(-123),
+		},
+		{
+			typeName: "sbcd32",
+			value:    []byte{0x01, 0x23, 0x45, 0x67},
+			expected: int32(1234567),
+		},
+		{
+			typeName: "sbcd32",
+			value:    []byte{0xf9, 0x99, 0x99, 0x99},
+			expected: int32(-9999999),
+		},
+
+		// modulo 10000 integers
+		{
+			typeName: "u32m10",
+			value:    []byte{0x04, 0xd2, 0x16, 0x2e}, // 1234, 5678
+			expected: uint32(56781234),
+		},
+		{
+			typeName: "uint32m10",
+			value:    []byte{0x27, 0x0f, 0x27, 0x0f}, // 9999, 9999
+			expected: uint32(99999999),
+		},
+		{
+			typeName: "u48m10",
+			value:    []byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x03}, // 1, 2, 3
+			expected: uint64(300020001),
+		},
+		{
+			typeName: "u64m10",
+			value:    []byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04}, // 1, 2, 3, 4
+			expected: uint64(4000300020001),
+		},
+		{
+			typeName: "s32m10",
+			value:    []byte{0xfb, 0x2e, 0xff, 0xff}, // -1234, -1
+			expected: int32(-11234),
+		},
+		{
+			typeName: "int48m10",
+			value:    []byte{0x00, 0x05, 0x00, 0x00, 0x00, 0x07}, // 5, 0, 7
+			expected: int64(700000005),
+		},
+		{
+			typeName: "int64m10",
+			value:    []byte{0xff, 0xfe, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff}, // -2, 0, 0, -1
+			expected: int64(-1000000000002),
+		},
+
+		// 16-bit floating point number
+		{
+			typeName: "f16",
+			value:    []byte{0x3c, 0x00},
+			expected: float32(1),
+		},
+		{
+			typeName: "float16",
+			value:    []byte{0xc0, 0x00},
+			expected: float32(-2),
+		},
+		{
+			typeName: "f16",
+			value:    []byte{0x7b, 0xff},
+			expected: float32(65504),
+		},
+		{
+			typeName: "f16",
+			value:    []byte{0x00, 0x01},
+			expected: float32(5.9604645e-08),
+		},
+		{
+			typeName: "f16",
+			value:    []byte{0x7c, 0x00},
+			expected: float32(math.Inf(1)),
+		},
+
+		// 24-bit integers
+		{
+			typeName: "u24",
+			value:    []byte{0x01, 0x02, 0x03},
+			expected: uint32(0x010203),
+		},
+		{
+			typeName: "uint24",
+			value:    []byte{0xaa, 0xff, 0xff, 0xff},
+			expected: uint32(0xffffff),
+		},
+		{
+			typeName: "s24",
+			value:    []byte{0xff, 0xff, 0xfe},
+			expected: int32(-2),
+		},
+		{
+			typeName: "int24",
+			value:    []byte{0x00, 0x7f, 0xff, 0xff},
+			expected: int32(0x7fffff),
+		},
+
+		// 48-bit integers
+		{
+			typeName: "u48",
+			value:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+			expected: uint64(0x010203040506),
+		},
+		{
+			typeName: "uint48",
+			value:    []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			expected: uint64(0xffffffffffff),
+		},
+		{
+			typeName: "s48",
+			value:    []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			expected: int64(-1),
+		},
+		{
+			typeName: "int48",
+			value:    []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: int64(-0x800000000000),
+		},
+
+		// sign-magnitude integers
+		{
+			typeName: "sm16",
+			value:    []byte{0x80, 0x05},
+			expected: int16(-5),
+		},
+		{
+			typeName: "sm16",
+			value:    []byte{0x00, 0x05},
+			expected: int16(5),
+		},
+		{
+			typeName: "sm32",
+			value:    []byte{0xff, 0xff, 0xff, 0xff},
+			expected: int32(-0x7fffffff),
+		},
+		{
+			typeName: "sm64",
+			value:    []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00},
+			expected: int64(-256),
+		},
+
+		// fixed-point numbers
+		{
+			typeName: "q7.8",
+			value:    []byte{0x01, 0x80},
+			expected: float64(1.5),
+		},
+		{
+			typeName: "q15",
+			value:    []byte{0xc0, 0x00},
+			expected: float64(-0.5),
+		},
+		{
+			typeName: "uq8.8",
+			value:    []byte{0xff, 0x40},
+			expected: float64(255.25),
+		},
+		{
+			typeName: "Q15.16",
+			value:    []byte{0xff, 0xfe, 0x80, 0x00},
+			expected: float64(-1.5),
+		},
+		{
+			typeName: "uq32.32",
+			value:    []byte{0x00, 0x00, 0x00, 0x02, 0x40, 0x00, 0x00, 0x00},
+			expected: float64(2.25),
+		},
+
+		// dates, times and durations
+		{
+			typeName: "unix32",
+			value:    []byte{0x5e, 0x0b, 0xe1, 0x00}, // 1577836800
+			expected: "2020-01-01T00:00:00Z",
+		},
+		{
+			typeName: "epoch64",
+			value:    []byte{0x00, 0x00, 0x00, 0x00, 0x5e, 0x0b, 0xe1, 0x3d}, // 1577836861
+			expected: "2020-01-01T00:01:01Z",
+		},
+		{
+			typeName: "epoch2000",
+			value:    []byte{0x00, 0x01, 0x51, 0x80}, // 86400
+			expected: "2000-01-02T00:00:00Z",
+		},
+		{
+			typeName: "datetime",
+			value:    []byte{0x07, 0xe4, 0x00, 0x02, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x3b, 0x00, 0x3a}, // 2020, 2, 29, 23, 59, 58
+			expected: "2020-02-29T23:59:58Z",
+		},
+		{
+			typeName: "datetimePacked",
+			value:    []byte{0x15, 0x0c, 0x1f, 0x08, 0x1e, 0x00}, // 21, 12, 31, 8, 30, 0
+			expected: "2021-12-31T08:30:00Z",
+		},
+		{
+			typeName: "duration32",
+			value:    []byte{0x00, 0x01, 0x5f, 0x90}, // 90000
+			expected: "25h0m0s",
+		},
+		{
+			typeName: "duration64",
+			value:    []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3d}, // 61
+			expected: "1m1s",
+		},
+
 		// mac address
 		{
 			typeName:       "macAddress",
@@ -340,18 +577,6 @@ func TestCastToType_Ok(t *testing.T) {
 			value:    []byte{0x25, 0x35, 0x42, 0x95},
 			expected: float32(74.572670),
 		},
-		// cpmModelNumber
-		{
-			typeName: "cpmModelNumber",
-			value:    []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46, 0x34, 0x30, 0x30, 0x39, 0x30, 0x33, 0x36},
-			expected: "F4009036",
-		},
-		// cpmSerialNumber
-		{
-			typeName: "cpmSerialNumber",
-			value:    []byte{0x2d, 0x53, 0x31, 0x2d, 0x32, 0x31, 0x2d, 0x00, 0x30, 0x30, 0x33, 0x30, 0x31, 0x37, 0x37, 0x00},
-			expected: "-S1-21- 0030177",
-		},
 	}
 
 	for i, tt := range tests {
@@ -412,6 +637,120 @@ func TestCastToType_Error(t *testing.T) {
 			typeName: "macAddressWide",
 			value:    []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c},
 		},
+		// Invalid digits for binary-coded decimal
+		{
+			typeName: "bcd16",
+			value:    []byte{0x12, 0x3a},
+		},
+		{
+			typeName: "bcd32",
+			value:    []byte{0x12, 0x34, 0xf6, 0x78},
+		},
+		{
+			typeName: "sbcd16",
+			value:    []byte{0x81, 0x23},
+		},
+		{
+			typeName: "sbcd32",
+			value:    []byte{0xf1, 0x23, 0x45, 0x6b},
+		},
+		// Invalid width for binary-coded decimal
+		{
+			typeName: "bcd16",
+			value:    []byte{0x12},
+		},
+		{
+			typeName: "bcd32",
+			value:    []byte{0x12, 0x34},
+		},
+		{
+			typeName: "sbcd32",
+			value:    []byte{},
+		},
+		// Invalid registers for modulo 10000 integers
+		{
+			typeName: "u32m10",
+			value:    []byte{0x27, 0x10, 0x00, 0x00}, // 10000 is out of range
+		},
+		{
+			typeName: "u64m10",
+			value:    []byte{0x00, 0x01, 0x00, 0x02},
+		},
+		{
+			typeName: "s32m10",
+			value:    []byte{0x00, 0x01, 0xff, 0xff}, // mixed signs
+		},
+		{
+			typeName: "s48m10",
+			value:    []byte{0xd8, 0xf0, 0x00, 0x00, 0x00, 0x00}, // -10000 is out of range
+		},
+		// Invalid widths for half floats, 24/48-bit, sign-magnitude and fixed-point
+		{
+			typeName: "f16",
+			value:    []byte{0x3c, 0x00, 0x00, 0x00},
+		},
+		{
+			typeName: "u24",
+			value:    []byte{0x01, 0x02},
+		},
+		{
+			typeName: "s24",
+			value:    []byte{0x01, 0x02, 0x03, 0x04, 0x05},
+		},
+		{
+			typeName: "u48",
+			value:    []byte{0x01, 0x02, 0x03, 0x04},
+		},
+		{
+			typeName: "s48",
+			value:    []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07},
+		},
+		{
+			typeName: "sm16",
+			value:    []byte{0x80, 0x05, 0x00, 0x00},
+		},
+		{
+			typeName: "sm32",
+			value:    []byte{0x80, 0x05},
+		},
+		{
+			typeName: "q7.8",
+			value:    []byte{0x01, 0x80, 0x00, 0x00},
+		},
+		// Invalid fixed-point formats
+		{
+			typeName: "q7.7",
+			value:    []byte{0x01, 0x80},
+		},
+		{
+			typeName: "uq8.x",
+			value:    []byte{0x01, 0x80},
+		},
+		// Invalid dates, times and durations
+		{
+			typeName: "unix32",
+			value:    []byte{0x5e, 0x0b},
+		},
+		{
+			typeName: "unix64",
+			value:    []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			typeName: "datetime",
+			value:    []byte{0x07, 0xe3, 0x00, 0x02, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x3b, 0x00, 0x3a}, // 2019-02-29
+		},
+		{
+			typeName: "datetime",
+			value:    []byte{0x07, 0xe4, 0x00, 0x0d, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // month 13
+		},
+		{
+			typeName: "datetimepacked",
+			value:    []byte{0x15, 0x0c, 0x1f, 0x18, 0x1e, 0x00}, // hour 24
+		},
+		{
+			typeName: "duration32",
+			value:    []byte{0x00, 0x01},
+		},
 		// bytes are deprecated
 		{
 			typeName: "bytes",
@@ -442,3 +781,152 @@ func TestCastToType_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFixedPointType(t *testing.T) {
+	var tests = []struct {
+		typeName string
+		signed   bool
+		bits     uint
+		fraction uint
+		ok       bool
+	}{
+		{typeName: "q15", signed: true, bits: 16, fraction: 15, ok: true},
+		{typeName: "q7.8", signed: true, bits: 16, fraction: 8, ok: true},
+		{typeName: "Q15.16", signed: true, bits: 32, fraction: 16, ok: true},
+		{typeName: "uq16", signed: false, bits: 16, fraction: 16, ok: true},
+		{typeName: "uq8.8", signed: false, bits: 16, fraction: 8, ok: true},
+		{typeName: "uq32.32", signed: false, bits: 64, fraction: 32, ok: true},
+		{typeName: "q16", ok: false},
+		{typeName: "uq8.9", ok: false},
+		{typeName: "q", ok: false},
+		{typeName: "f32", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typeName, func(t *testing.T) {
+			signed, bits, fraction, ok := ParseFixedPointType(tt.typeName)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.signed, signed)
+				assert.Equal(t, tt.bits, bits)
+				assert.Equal(t, tt.fraction, fraction)
+			}
+		})
+	}
+}
+
+func TestDecodeString_Ok(t *testing.T) {
+	space := " "
+	empty := ""
+	var tests = []struct {
+		name     string
+		value    []byte
+		opts     *StringOptions
+		expected string
+	}{
+		{
+			name:     "no options",
+			value:    []byte{0x34, 0x2e, 0x30, 0x00, 0x36},
+			opts:     nil,
+			expected: "4.0",
+		},
+		{
+			name:     "byte swap",
+			value:    []byte{0x42, 0x41, 0x44, 0x43},
+			opts:     &StringOptions{ByteSwap: true},
+			expected: "ABCD",
+		},
+		{
+			name:     "trim leading nul",
+			value:    []byte{0x00, 0x00, 0x00, 0x00, 0x46, 0x34, 0x30, 0x30},
+			opts:     &StringOptions{Trim: "nul"},
+			expected: "F400",
+		},
+		{
+			name:     "trim space",
+			value:    []byte{0x20, 0x41, 0x42, 0x20},
+			opts:     &StringOptions{Trim: "space"},
+			expected: "AB",
+		},
+		{
+			name:     "trim all and replace nul",
+			value:    []byte{0x2d, 0x53, 0x31, 0x2d, 0x32, 0x31, 0x2d, 0x00, 0x30, 0x30, 0x33, 0x30, 0x31, 0x37, 0x37, 0x00},
+			opts:     &StringOptions{Trim: "all", ReplaceNul: &space},
+			expected: "-S1-21- 0030177",
+		},
+		{
+			name:     "remove nul",
+			value:    []byte{0x41, 0x00, 0x42, 0x00},
+			opts:     &StringOptions{ReplaceNul: &empty},
+			expected: "AB",
+		},
+		{
+			name:     "ascii",
+			value:    []byte{0x41, 0xe9},
+			opts:     &StringOptions{Encoding: "ascii"},
+			expected: "A\ufffd",
+		},
+		{
+			name:     "latin1",
+			value:    []byte{0x63, 0x61, 0x66, 0xe9},
+			opts:     &StringOptions{Encoding: "latin1"},
+			expected: "café",
+		},
+		{
+			name:     "utf16 big endian",
+			value:    []byte{0x00, 0x48, 0x00, 0xe9, 0x00, 0x00},
+			opts:     &StringOptions{Encoding: "utf16"},
+			expected: "Hé",
+		},
+		{
+			name:     "utf16 little endian",
+			value:    []byte{0x48, 0x00, 0xe9, 0x00},
+			opts:     &StringOptions{Encoding: "utf16le"},
+			expected: "Hé",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := DecodeString(tt.value, tt.opts)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDecodeString_Error(t *testing.T) {
+	var tests = []struct {
+		name  string
+		value []byte
+		opts  *StringOptions
+	}{
+		{
+			name:  "byte swap odd length",
+			value: []byte{0x41, 0x42, 0x43},
+			opts:  &StringOptions{ByteSwap: true},
+		},
+		{
+			name:  "utf16 odd length",
+			value: []byte{0x00, 0x41, 0x00},
+			opts:  &StringOptions{Encoding: "utf16"},
+		},
+		{
+			name:  "unknown encoding",
+			value: []byte{0x41},
+			opts:  &StringOptions{Encoding: "ebcdic"},
+		},
+		{
+			name:  "unknown trim",
+			value: []byte{0x41},
+			opts:  &StringOptions{Trim: "tabs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeString(tt.value, tt.opts)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package cpm contains the busway CPM vendor types. They are added to the type
+// registry with utils.RegisterType, the same way a plugin embedding this one
+// would add its own vendor types, when the package is imported.
+package cpm
+
+import (
+	"strings"
+
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+)
+
+// ModelNumber is specific to busway CPM data at register 0x12C.
+// The documentation for this is "Model Number" and tech support is not there.
+// We see hex data coming in like 0x00000000000000004634303039303335.
+// In this case, trim the leading 0x00s and convert to string.
+// Data above converts to F4009036
+// New devices should use stringOptions (trim: nul) instead.
+func ModelNumber(b []byte) (out string) {
+	i := 0
+	for ; i < len(b); i++ {
+		if b[i] != 0x00 {
+			break
+		}
+	}
+	return string(b[i:])
+}
+
+// SerialNumber is specific to busway CPM data at register 0x134.
+// The documentation for this is "Serial Number" and tech support is not there.
+// We see hex data coming in like 0x2d53312d383838003030323937303800.
+// In this case, convert 0x00 to ASCII space and convert to string.
+// Trim leading and trailing whitespace.
+// Data above converts to -S1-21- 0030177
+// New devices should use stringOptions (trim: all, replaceNul: " ") instead.
+func SerialNumber(b []byte) (out string) {
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0x00 {
+			b[i] = 0x20
+		}
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func init() {
+	types := []utils.TypeDecoder{
+		{
+			// Busway CPM Model Number.
+			Name:   "cpmmodelnumber",
+			Decode: func(v []byte) (interface{}, error) { return ModelNumber(v), nil },
+		},
+		{
+			// Busway CPM Serial Number.
+			Name:   "cpmserialnumber",
+			Decode: func(v []byte) (interface{}, error) { return SerialNumber(v), nil },
+		},
+	}
+	for _, t := range types {
+		if err := utils.RegisterType(t); err != nil {
+			panic(err)
+		}
+	}
+}
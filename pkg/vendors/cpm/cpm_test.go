@@ -0,0 +1,40 @@
+package cpm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vapor-ware/synse-modbus-ip-plugin/pkg/utils"
+)
+
+func TestCastToType(t *testing.T) {
+	var tests = []struct {
+		typeName string
+		value    []byte
+		expected interface{}
+	}{
+		{
+			typeName: "cpmModelNumber",
+			value:    []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x46, 0x34, 0x30, 0x30, 0x39, 0x30, 0x33, 0x36},
+			expected: "F4009036",
+		},
+		{
+			typeName: "cpmSerialNumber",
+			value:    []byte{0x2d, 0x53, 0x31, 0x2d, 0x32, 0x31, 0x2d, 0x00, 0x30, 0x30, 0x33, 0x30, 0x31, 0x37, 0x37, 0x00},
+			expected: "-S1-21- 0030177",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typeName, func(t *testing.T) {
+			actual, err := utils.CastToType(tt.typeName, tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, actual)
+
+			registered := utils.LookupType(tt.typeName)
+			assert.NotNil(t, registered)
+			assert.Equal(t, uint16(0), registered.Width)
+			assert.False(t, registered.String)
+		})
+	}
+}